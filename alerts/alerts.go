@@ -0,0 +1,235 @@
+package alerts
+
+import (
+	"context"
+	"log"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// rainWindow and windWindow are how far ahead the forecast is checked
+	// for rain and wind rules.
+	rainWindow = 6 * time.Hour
+	windWindow = 24 * time.Hour
+
+	fetchTimeout   = 10 * time.Second
+	sendTimeout    = 15 * time.Second
+	eventRetention = 7 * 24 * time.Hour
+)
+
+type repository interface {
+	AllAlertRules(ctx context.Context) ([]models.AlertRule, error)
+	RecordAlertEvent(ctx context.Context, ruleID int64, day time.Time) (bool, error)
+	DeleteAlertEvent(ctx context.Context, ruleID int64, day time.Time) error
+	DeleteAlertEventsBefore(ctx context.Context, day time.Time) error
+	WarningSubscriptions(ctx context.Context) ([]models.WarningSubscription, error)
+	RecordWarning(ctx context.Context, userID int64, key string, endsAt time.Time) (bool, error)
+	DeleteWarning(ctx context.Context, userID int64, key string) error
+	DeleteWarningsEndedBefore(ctx context.Context, t time.Time) error
+}
+
+type weatherProvider interface {
+	Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error)
+	Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error)
+}
+
+// notifier builds the notifications in the language and units of the user.
+type notifier interface {
+	AlertMessage(ctx context.Context, rule models.AlertRule, event Event) (string, error)
+	WarningMessage(ctx context.Context, userID int64, location models.Location, alert weather.Alert) (string, error)
+}
+
+type botAPI interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+// Event is a forecast crossing the threshold of a rule.
+type Event struct {
+	Day   time.Time // local day of the event, a rule fires once per day
+	Time  time.Time // forecast slot for rain and wind rules
+	Value float64   // forecast value in the metric units of the threshold
+}
+
+// Evaluator checks the alert rules of users against the forecast and pushes
+// official warnings to subscribed users.
+type Evaluator struct {
+	bot      botAPI
+	repo     repository
+	provider weatherProvider
+	notifier notifier
+	interval time.Duration
+	now      func() time.Time
+}
+
+func New(bot botAPI, repo repository, provider weatherProvider, notifier notifier, interval time.Duration) *Evaluator {
+	return &Evaluator{
+		bot:      bot,
+		repo:     repo,
+		provider: provider,
+		notifier: notifier,
+		interval: interval,
+		now:      time.Now,
+	}
+}
+
+// Start evaluates all rules and checks for new warnings every interval until
+// ctx is cancelled.
+func (e *Evaluator) Start(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	log.Println("Alert evaluator started...")
+
+	for {
+		e.run(ctx)
+		e.runWarnings(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Alert evaluator stopped gracefully.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// run checks every rule against the forecast for its location. The forecast
+// of a location is fetched once however many rules it has.
+func (e *Evaluator) run(ctx context.Context) {
+	rules, err := e.repo.AllAlertRules(ctx)
+	if err != nil {
+		log.Println("error repo.AllAlertRules: ", err)
+		return
+	}
+
+	now := e.now()
+	forecasts := make(map[int64]*weather.Forecast)
+	for _, rule := range rules {
+		if ctx.Err() != nil {
+			return
+		}
+		// Locations saved before coordinates were stored are geocoded when
+		// the rule is created, the rest have nothing to check yet.
+		if !rule.Location.Geocoded {
+			continue
+		}
+
+		forecast, ok := forecasts[rule.Location.ID]
+		if !ok {
+			forecast = e.fetch(ctx, rule.Location)
+			forecasts[rule.Location.ID] = forecast
+		}
+		if forecast == nil {
+			continue
+		}
+
+		event, ok := Evaluate(rule, *forecast, now)
+		if ok {
+			e.notify(ctx, rule, event)
+		}
+	}
+
+	err = e.repo.DeleteAlertEventsBefore(ctx, now.Add(-eventRetention))
+	if err != nil {
+		log.Println("error repo.DeleteAlertEventsBefore: ", err)
+	}
+}
+
+// fetch returns the forecast in metric units, thresholds are stored in them.
+// It returns nil when the forecast is not available.
+func (e *Evaluator) fetch(ctx context.Context, location models.Location) *weather.Forecast {
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	forecast, err := e.provider.Forecast(fetchCtx, location.Lat, location.Lon, weather.Params{Units: weather.Metric})
+	if err != nil {
+		log.Printf("error provider.Forecast for location %d: %v", location.ID, err)
+		return nil
+	}
+	return &forecast
+}
+
+// notify sends the event unless the rule has already fired that day. The
+// event is forgotten when it can't be sent so the next run retries it.
+func (e *Evaluator) notify(ctx context.Context, rule models.AlertRule, event Event) {
+	// A notification being sent is finished even if shutdown starts.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+
+	recorded, err := e.repo.RecordAlertEvent(ctx, rule.ID, event.Day)
+	if err != nil {
+		log.Printf("error repo.RecordAlertEvent for rule %d: %v", rule.ID, err)
+		return
+	}
+	if !recorded {
+		return
+	}
+
+	text, err := e.notifier.AlertMessage(ctx, rule, event)
+	if err == nil {
+		_, err = e.bot.Send(tgbotapi.NewMessage(rule.ChatID, text))
+	}
+	if err != nil {
+		log.Printf("error sending alert for rule %d: %v", rule.ID, err)
+		err = e.repo.DeleteAlertEvent(ctx, rule.ID, event.Day)
+		if err != nil {
+			log.Printf("error repo.DeleteAlertEvent for rule %d: %v", rule.ID, err)
+		}
+	}
+}
+
+// Evaluate reports whether the forecast crosses the threshold of the rule
+// and describes the first such moment.
+func Evaluate(rule models.AlertRule, forecast weather.Forecast, now time.Time) (Event, bool) {
+	loc := time.FixedZone("", forecast.Timezone)
+	local := now.In(loc)
+
+	switch rule.Kind {
+	case models.AlertCold:
+		tomorrow := time.Date(local.Year(), local.Month(), local.Day()+1, 0, 0, 0, 0, loc)
+		for _, day := range forecast.Days {
+			if day.Date.Equal(tomorrow) && day.TempMin < rule.Threshold {
+				return Event{Day: tomorrow, Value: day.TempMin}, true
+			}
+		}
+	case models.AlertRain:
+		for _, hour := range upcoming(forecast, now, rainWindow) {
+			if pop := hour.Pop * 100; pop > rule.Threshold {
+				return hourEvent(hour, loc, pop), true
+			}
+		}
+	case models.AlertWind:
+		for _, hour := range upcoming(forecast, now, windWindow) {
+			if hour.WindGust > rule.Threshold {
+				return hourEvent(hour, loc, hour.WindGust), true
+			}
+		}
+	}
+
+	return Event{}, false
+}
+
+// upcoming returns the forecast slots that end after now and start within
+// window.
+func upcoming(forecast weather.Forecast, now time.Time, window time.Duration) []weather.ForecastHour {
+	var slots []weather.ForecastHour
+	for _, hour := range forecast.Hours {
+		if hour.Time.Add(forecast.Step).After(now) && hour.Time.Before(now.Add(window)) {
+			slots = append(slots, hour)
+		}
+	}
+	return slots
+}
+
+func hourEvent(hour weather.ForecastHour, loc *time.Location, value float64) Event {
+	local := hour.Time.In(loc)
+	return Event{
+		Day:   time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc),
+		Time:  local,
+		Value: value,
+	}
+}
@@ -0,0 +1,259 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type mockRepo struct {
+	rules    []models.AlertRule
+	events   map[string]bool
+	subs     []models.WarningSubscription
+	warnings map[string]bool
+}
+
+func (m *mockRepo) AllAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	return m.rules, nil
+}
+func (m *mockRepo) RecordAlertEvent(ctx context.Context, ruleID int64, day time.Time) (bool, error) {
+	key := fmt.Sprintf("%d/%s", ruleID, day.Format(time.DateOnly))
+	if m.events[key] {
+		return false, nil
+	}
+	m.events[key] = true
+	return true, nil
+}
+func (m *mockRepo) DeleteAlertEvent(ctx context.Context, ruleID int64, day time.Time) error {
+	delete(m.events, fmt.Sprintf("%d/%s", ruleID, day.Format(time.DateOnly)))
+	return nil
+}
+func (m *mockRepo) DeleteAlertEventsBefore(ctx context.Context, day time.Time) error {
+	return nil
+}
+func (m *mockRepo) WarningSubscriptions(ctx context.Context) ([]models.WarningSubscription, error) {
+	return m.subs, nil
+}
+func (m *mockRepo) RecordWarning(ctx context.Context, userID int64, key string, endsAt time.Time) (bool, error) {
+	key = fmt.Sprintf("%d/%s", userID, key)
+	if m.warnings[key] {
+		return false, nil
+	}
+	m.warnings[key] = true
+	return true, nil
+}
+func (m *mockRepo) DeleteWarning(ctx context.Context, userID int64, key string) error {
+	delete(m.warnings, fmt.Sprintf("%d/%s", userID, key))
+	return nil
+}
+func (m *mockRepo) DeleteWarningsEndedBefore(ctx context.Context, t time.Time) error {
+	return nil
+}
+
+type mockProvider struct {
+	calls    int
+	forecast weather.Forecast
+	alerts   []weather.Alert
+}
+
+func (m *mockProvider) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	m.calls++
+	if params.Units != weather.Metric {
+		return weather.Forecast{}, errors.New("thresholds are metric")
+	}
+	return m.forecast, nil
+}
+func (m *mockProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	m.calls++
+	return m.alerts, nil
+}
+
+type mockNotifier struct {
+	err error
+}
+
+func (m *mockNotifier) AlertMessage(ctx context.Context, rule models.AlertRule, event Event) (string, error) {
+	return fmt.Sprintf("rule %d: %v at %s", rule.ID, event.Value, event.Time.Format("15:04")), m.err
+}
+func (m *mockNotifier) WarningMessage(ctx context.Context, userID int64, location models.Location, alert weather.Alert) (string, error) {
+	return fmt.Sprintf("%s: %s", location.City, alert.Event), m.err
+}
+
+type mockBotAPI struct {
+	sent []tgbotapi.Chattable
+}
+
+func (m *mockBotAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	m.sent = append(m.sent, c)
+	return tgbotapi.Message{}, nil
+}
+
+// testForecast is a forecast for a city in UTC+3 requested at 2025-12-15
+// 10:00 local time.
+func testForecast() (weather.Forecast, time.Time) {
+	loc := time.FixedZone("", 3*60*60)
+	now := time.Date(2025, 12, 15, 10, 0, 0, 0, loc)
+	forecast := weather.Forecast{
+		Timezone: 3 * 60 * 60,
+		Step:     3 * time.Hour,
+		Hours: []weather.ForecastHour{
+			{Time: time.Date(2025, 12, 15, 9, 0, 0, 0, loc), Pop: 0.2, WindGust: 5},
+			{Time: time.Date(2025, 12, 15, 12, 0, 0, 0, loc), Pop: 0.7, WindGust: 8},
+			{Time: time.Date(2025, 12, 15, 18, 0, 0, 0, loc), Pop: 0.9, WindGust: 12},
+			{Time: time.Date(2025, 12, 16, 6, 0, 0, 0, loc), Pop: 0.1, WindGust: 18},
+			{Time: time.Date(2025, 12, 16, 12, 0, 0, 0, loc), Pop: 0, WindGust: 25},
+		},
+		Days: []weather.DailyForecast{
+			{Date: time.Date(2025, 12, 15, 0, 0, 0, 0, loc), TempMin: -12, TempMax: -5},
+			{Date: time.Date(2025, 12, 16, 0, 0, 0, 0, loc), TempMin: -8, TempMax: -2},
+		},
+	}
+	return forecast, now
+}
+
+func TestEvaluate(t *testing.T) {
+	forecast, now := testForecast()
+
+	tests := []struct {
+		name      string
+		kind      string
+		threshold float64
+		wantOK    bool
+		wantDay   int
+		wantTime  string
+		wantValue float64
+	}{
+		{"Cold tomorrow", models.AlertCold, -5, true, 16, "00:00", -8},
+		{"Cold today only", models.AlertCold, -10, false, 0, "", 0},
+		{"Rain in the current slot", models.AlertRain, 10, true, 15, "09:00", 20},
+		{"Rain later today", models.AlertRain, 60, true, 15, "12:00", 70},
+		{"Rain beyond six hours", models.AlertRain, 80, false, 0, "", 0},
+		{"Wind tomorrow morning", models.AlertWind, 15, true, 16, "06:00", 18},
+		{"Wind beyond a day", models.AlertWind, 20, false, 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := models.AlertRule{Kind: tt.kind, Threshold: tt.threshold}
+			event, ok := Evaluate(rule, forecast, now)
+			if ok != tt.wantOK {
+				t.Fatalf("Evaluate() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if event.Day.Day() != tt.wantDay || event.Day.Hour() != 0 {
+				t.Errorf("got event day %v, want local midnight of the %dth", event.Day, tt.wantDay)
+			}
+			if tt.kind != models.AlertCold && event.Time.Format("15:04") != tt.wantTime {
+				t.Errorf("got event time %v, want %v", event.Time.Format("15:04"), tt.wantTime)
+			}
+			if event.Value != tt.wantValue {
+				t.Errorf("got event value %v, want %v", event.Value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestEvaluator_Run(t *testing.T) {
+	forecast, now := testForecast()
+	home := models.Location{ID: 1, City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true}
+	repo := &mockRepo{
+		rules: []models.AlertRule{
+			{ID: 1, ChatID: 10, Kind: models.AlertRain, Threshold: 60, Location: home},
+			{ID: 2, ChatID: 10, Kind: models.AlertWind, Threshold: 30, Location: home},
+			{ID: 3, ChatID: 20, Kind: models.AlertCold, Threshold: 0, Location: home},
+			{ID: 4, ChatID: 30, Kind: models.AlertCold, Threshold: 0, Location: models.Location{ID: 2, City: "Istra"}},
+		},
+		events: map[string]bool{},
+	}
+	provider := &mockProvider{forecast: forecast}
+	notifier := &mockNotifier{}
+	bot := &mockBotAPI{}
+
+	e := New(bot, repo, provider, notifier, time.Minute)
+	e.now = func() time.Time { return now }
+
+	e.run(context.Background())
+
+	if provider.calls != 1 {
+		t.Errorf("got %d forecast requests, want 1 per location", provider.calls)
+	}
+	if len(bot.sent) != 2 {
+		t.Fatalf("got %d alerts sent, want 2", len(bot.sent))
+	}
+	if msg := bot.sent[0].(tgbotapi.MessageConfig); msg.ChatID != 10 || msg.Text != "rule 1: 70 at 12:00" {
+		t.Errorf("unexpected alert: %d %q", msg.ChatID, msg.Text)
+	}
+	if msg := bot.sent[1].(tgbotapi.MessageConfig); msg.ChatID != 20 {
+		t.Errorf("got cold alert sent to %d, want 20", msg.ChatID)
+	}
+
+	// The rain goes on in the evening, but the user has been told already.
+	e.now = func() time.Time { return now.Add(6 * time.Hour) }
+	e.run(context.Background())
+	if len(bot.sent) != 2 {
+		t.Errorf("got %d alerts sent after the second run, want no new ones", len(bot.sent))
+	}
+
+	// Events that could not be sent are retried.
+	repo.events = map[string]bool{}
+	notifier.err = errors.New("no user")
+	e.run(context.Background())
+	notifier.err = nil
+	e.run(context.Background())
+	if len(bot.sent) != 4 {
+		t.Errorf("got %d alerts sent after the retry, want 4", len(bot.sent))
+	}
+}
+
+func TestEvaluator_RunWarnings(t *testing.T) {
+	now := time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC)
+	home := models.Location{ID: 1, City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true}
+	repo := &mockRepo{
+		subs: []models.WarningSubscription{
+			{UserID: 1, ChatID: 10, Location: home},
+			{UserID: 2, ChatID: 20, Location: home},
+		},
+		warnings: map[string]bool{},
+	}
+	provider := &mockProvider{
+		alerts: []weather.Alert{
+			{Sender: "Roshydromet", Event: "Strong wind", Start: now, End: now.Add(12 * time.Hour)},
+		},
+	}
+	bot := &mockBotAPI{}
+
+	e := New(bot, repo, provider, &mockNotifier{}, time.Minute)
+	e.now = func() time.Time { return now }
+
+	e.runWarnings(context.Background())
+
+	if provider.calls != 1 {
+		t.Errorf("got %d alerts requests, want 1 per location", provider.calls)
+	}
+	if len(bot.sent) != 2 {
+		t.Fatalf("got %d warnings sent, want 2", len(bot.sent))
+	}
+	if msg := bot.sent[0].(tgbotapi.MessageConfig); msg.ChatID != 10 || msg.Text != "Moscow: Strong wind" {
+		t.Errorf("unexpected warning: %d %q", msg.ChatID, msg.Text)
+	}
+
+	// Only warnings that appear later are sent again.
+	provider.alerts = append(provider.alerts, weather.Alert{
+		Sender: "Roshydromet", Event: "Heavy snow", Start: now.Add(time.Hour), End: now.Add(6 * time.Hour),
+	})
+	e.runWarnings(context.Background())
+	if len(bot.sent) != 4 {
+		t.Fatalf("got %d warnings sent, want 4", len(bot.sent))
+	}
+	if msg := bot.sent[3].(tgbotapi.MessageConfig); msg.ChatID != 20 || msg.Text != "Moscow: Heavy snow" {
+		t.Errorf("unexpected warning: %d %q", msg.ChatID, msg.Text)
+	}
+}
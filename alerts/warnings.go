@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// runWarnings sends subscribed users the official warnings for their default
+// location they haven't been sent yet.
+func (e *Evaluator) runWarnings(ctx context.Context) {
+	subs, err := e.repo.WarningSubscriptions(ctx)
+	if err != nil {
+		log.Println("error repo.WarningSubscriptions: ", err)
+		return
+	}
+
+	warnings := make(map[int64][]weather.Alert)
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if !sub.Location.Geocoded {
+			continue
+		}
+
+		alerts, ok := warnings[sub.Location.ID]
+		if !ok {
+			alerts = e.fetchWarnings(ctx, sub.Location)
+			warnings[sub.Location.ID] = alerts
+		}
+
+		for _, alert := range alerts {
+			e.sendWarning(ctx, sub, alert)
+		}
+	}
+
+	err = e.repo.DeleteWarningsEndedBefore(ctx, e.now())
+	if err != nil {
+		log.Println("error repo.DeleteWarningsEndedBefore: ", err)
+	}
+}
+
+func (e *Evaluator) fetchWarnings(ctx context.Context, location models.Location) []weather.Alert {
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+
+	alerts, err := e.provider.Alerts(fetchCtx, location.Lat, location.Lon)
+	if errors.Is(err, weather.ErrNotSupported) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("error provider.Alerts for location %d: %v", location.ID, err)
+		return nil
+	}
+	return alerts
+}
+
+// sendWarning sends the warning unless the user has already got it. The
+// warning is forgotten when it can't be sent so the next run retries it.
+func (e *Evaluator) sendWarning(ctx context.Context, sub models.WarningSubscription, alert weather.Alert) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+	defer cancel()
+
+	key := warningKey(alert)
+	recorded, err := e.repo.RecordWarning(ctx, sub.UserID, key, alert.End)
+	if err != nil {
+		log.Printf("error repo.RecordWarning for user %d: %v", sub.UserID, err)
+		return
+	}
+	if !recorded {
+		return
+	}
+
+	text, err := e.notifier.WarningMessage(ctx, sub.UserID, sub.Location, alert)
+	if err == nil {
+		_, err = e.bot.Send(tgbotapi.NewMessage(sub.ChatID, text))
+	}
+	if err != nil {
+		log.Printf("error sending warning to user %d: %v", sub.UserID, err)
+		err = e.repo.DeleteWarning(ctx, sub.UserID, key)
+		if err != nil {
+			log.Printf("error repo.DeleteWarning for user %d: %v", sub.UserID, err)
+		}
+	}
+}
+
+// warningKey identifies a warning across requests, the provider gives
+// warnings no IDs.
+func warningKey(alert weather.Alert) string {
+	return fmt.Sprintf("%s|%s|%d", alert.Sender, alert.Event, alert.Start.Unix())
+}
@@ -0,0 +1,220 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"study/weatherbot/weather"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	// loadTimeout bounds an upstream request shared by several callers, it
+	// outlives the context of the caller that started it.
+	loadTimeout = 10 * time.Second
+	// sweepThreshold is the number of entries after which expired ones are
+	// removed on insert.
+	sweepThreshold = 10000
+	// nowcastTTL bounds the age of minute by minute precipitation, which is
+	// outdated sooner than the rest of the weather.
+	nowcastTTL = 5 * time.Minute
+)
+
+type Provider interface {
+	Coordinates(ctx context.Context, city string) (weather.Coordinate, error)
+	Cities(ctx context.Context, city string) ([]weather.Coordinate, error)
+	ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error)
+	Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error)
+	Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error)
+	Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error)
+	AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error)
+	Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error)
+}
+
+// CachedProvider memoizes geocoding by normalized city name and weather by
+// rounded coordinates. Concurrent misses for the same key share a single
+// upstream request.
+type CachedProvider struct {
+	provider Provider
+	cities   *ttlCache[[]weather.Coordinate]
+	weather  *ttlCache[weather.Weather]
+	forecast *ttlCache[weather.Forecast]
+	alerts   *ttlCache[[]weather.Alert]
+	air      *ttlCache[weather.AirPollution]
+	nowcast  *ttlCache[weather.Nowcast]
+}
+
+func New(provider Provider, coordinatesTTL time.Duration, weatherTTL time.Duration) *CachedProvider {
+	return &CachedProvider{
+		provider: provider,
+		cities:   newTTLCache[[]weather.Coordinate](coordinatesTTL),
+		weather:  newTTLCache[weather.Weather](weatherTTL),
+		forecast: newTTLCache[weather.Forecast](weatherTTL),
+		alerts:   newTTLCache[[]weather.Alert](weatherTTL),
+		air:      newTTLCache[weather.AirPollution](weatherTTL),
+		nowcast:  newTTLCache[weather.Nowcast](min(weatherTTL, nowcastTTL)),
+	}
+}
+
+func (c *CachedProvider) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	cities, err := c.Cities(ctx, city)
+	if err != nil {
+		return weather.Coordinate{}, err
+	}
+
+	return cities[0], nil
+}
+
+func (c *CachedProvider) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	return c.cities.get(ctx, cityKey(city), func(ctx context.Context) ([]weather.Coordinate, error) {
+		return c.provider.Cities(ctx, city)
+	})
+}
+
+func (c *CachedProvider) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	return c.provider.ReverseGeocode(ctx, lat, lon)
+}
+
+func (c *CachedProvider) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	return c.weather.get(ctx, pointKey(lat, lon, params), func(ctx context.Context) (weather.Weather, error) {
+		return c.provider.Weather(ctx, lat, lon, params)
+	})
+}
+
+func (c *CachedProvider) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	return c.forecast.get(ctx, pointKey(lat, lon, params), func(ctx context.Context) (weather.Forecast, error) {
+		return c.provider.Forecast(ctx, lat, lon, params)
+	})
+}
+
+func (c *CachedProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	return c.alerts.get(ctx, pointKey(lat, lon, weather.Params{}), func(ctx context.Context) ([]weather.Alert, error) {
+		return c.provider.Alerts(ctx, lat, lon)
+	})
+}
+
+func (c *CachedProvider) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	return c.air.get(ctx, pointKey(lat, lon, weather.Params{}), func(ctx context.Context) (weather.AirPollution, error) {
+		return c.provider.AirPollution(ctx, lat, lon)
+	})
+}
+
+func (c *CachedProvider) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	return c.nowcast.get(ctx, pointKey(lat, lon, weather.Params{}), func(ctx context.Context) (weather.Nowcast, error) {
+		return c.provider.Nowcast(ctx, lat, lon)
+	})
+}
+
+type Stats struct {
+	Hits   int64 `json:"hits"`
+	Misses int64 `json:"misses"`
+}
+
+// Stats reports hit and miss counts per cached method.
+func (c *CachedProvider) Stats() map[string]Stats {
+	return map[string]Stats{
+		"coordinates": c.cities.stats(),
+		"weather":     c.weather.stats(),
+		"forecast":    c.forecast.stats(),
+		"alerts":      c.alerts.stats(),
+		"air":         c.air.stats(),
+		"nowcast":     c.nowcast.stats(),
+	}
+}
+
+func cityKey(city string) string {
+	return strings.ToLower(strings.Join(strings.Fields(city), " "))
+}
+
+// pointKey rounds coordinates to about a kilometre, weather doesn't differ
+// within that distance.
+func pointKey(lat float64, lon float64, params weather.Params) string {
+	return fmt.Sprintf("%.2f,%.2f|%s|%s", lat, lon, params.Lang, params.Units)
+}
+
+type entry[T any] struct {
+	value     T
+	expiresAt time.Time
+}
+
+type ttlCache[T any] struct {
+	ttl   time.Duration
+	now   func() time.Time
+	group singleflight.Group
+
+	mu      sync.Mutex
+	entries map[string]entry[T]
+
+	hits   atomic.Int64
+	misses atomic.Int64
+}
+
+func newTTLCache[T any](ttl time.Duration) *ttlCache[T] {
+	return &ttlCache[T]{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]entry[T]),
+	}
+}
+
+func (c *ttlCache[T]) get(ctx context.Context, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && c.now().Before(e.expiresAt) {
+		c.hits.Add(1)
+		return e.value, nil
+	}
+
+	c.misses.Add(1)
+
+	// The load is detached from ctx so that a caller giving up doesn't fail
+	// the other callers waiting for the same key.
+	ch := c.group.DoChan(key, func() (any, error) {
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		value, err := load(loadCtx)
+		if err != nil {
+			return value, err
+		}
+		c.set(key, value)
+		return value, nil
+	})
+
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			var zero T
+			return zero, res.Err
+		}
+		return res.Val.(T), nil
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	}
+}
+
+func (c *ttlCache[T]) set(key string, value T) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= sweepThreshold {
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+	}
+
+	c.entries[key] = entry[T]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+func (c *ttlCache[T]) stats() Stats {
+	return Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
@@ -0,0 +1,184 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"study/weatherbot/weather"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type mockProvider struct {
+	citiesCalls  atomic.Int64
+	weatherCalls atomic.Int64
+	release      chan struct{}
+	err          error
+}
+
+func (m *mockProvider) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	return weather.Coordinate{}, errors.New("Coordinates should be served from Cities")
+}
+func (m *mockProvider) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	m.citiesCalls.Add(1)
+	return []weather.Coordinate{{Name: "Moscow", Lat: 55.75, Lon: 37.61}}, m.err
+}
+func (m *mockProvider) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	return weather.Coordinate{}, nil
+}
+func (m *mockProvider) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	m.weatherCalls.Add(1)
+	if m.release != nil {
+		<-m.release
+	}
+	return weather.Weather{Temp: lat}, m.err
+}
+func (m *mockProvider) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	return weather.Forecast{}, m.err
+}
+func (m *mockProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	return nil, m.err
+}
+func (m *mockProvider) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	return weather.AirPollution{}, m.err
+}
+func (m *mockProvider) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	return weather.Nowcast{}, m.err
+}
+
+func TestCachedProvider_Coordinates(t *testing.T) {
+	provider := &mockProvider{}
+	c := New(provider, time.Hour, time.Minute)
+
+	for _, city := range []string{"Moscow", "  moscow ", "MOSCOW"} {
+		coord, err := c.Coordinates(context.Background(), city)
+		if err != nil {
+			t.Fatalf("Coordinates(%q) error = %v", city, err)
+		}
+		if coord.Name != "Moscow" {
+			t.Errorf("got Name %v, want Moscow", coord.Name)
+		}
+	}
+
+	if got := provider.citiesCalls.Load(); got != 1 {
+		t.Errorf("got %d upstream calls, want 1", got)
+	}
+	if got := c.Stats()["coordinates"]; got.Hits != 2 || got.Misses != 1 {
+		t.Errorf("got stats %+v, want 2 hits and 1 miss", got)
+	}
+}
+
+func TestCachedProvider_Weather(t *testing.T) {
+	provider := &mockProvider{}
+	c := New(provider, time.Hour, 10*time.Minute)
+
+	now := time.Date(2025, 12, 15, 8, 0, 0, 0, time.UTC)
+	c.weather.now = func() time.Time { return now }
+
+	tests := []struct {
+		name      string
+		lat, lon  float64
+		params    weather.Params
+		after     time.Duration
+		wantCalls int64
+	}{
+		{"First request", 55.7558, 37.6173, weather.Params{Lang: "ru"}, 0, 1},
+		{"Nearby point", 55.7561, 37.6169, weather.Params{Lang: "ru"}, time.Minute, 1},
+		{"Other language", 55.7558, 37.6173, weather.Params{Lang: "en"}, 0, 2},
+		{"Other units", 55.7558, 37.6173, weather.Params{Lang: "ru", Units: weather.Imperial}, 0, 3},
+		{"Other city", 59.9386, 30.3141, weather.Params{Lang: "ru"}, 0, 4},
+		{"Expired", 55.7558, 37.6173, weather.Params{Lang: "ru"}, 10 * time.Minute, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.after)
+			_, err := c.Weather(context.Background(), tt.lat, tt.lon, tt.params)
+			if err != nil {
+				t.Fatalf("Weather() error = %v", err)
+			}
+			if got := provider.weatherCalls.Load(); got != tt.wantCalls {
+				t.Errorf("got %d upstream calls, want %d", got, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCachedProvider_ErrorsAreNotCached(t *testing.T) {
+	provider := &mockProvider{err: errors.New("upstream is down")}
+	c := New(provider, time.Hour, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		_, err := c.Weather(context.Background(), 55.75, 37.61, weather.Params{})
+		if err == nil {
+			t.Fatal("Weather() error = nil, want upstream error")
+		}
+	}
+	if got := provider.weatherCalls.Load(); got != 2 {
+		t.Errorf("got %d upstream calls, want 2", got)
+	}
+}
+
+func TestCachedProvider_SingleFlight(t *testing.T) {
+	provider := &mockProvider{release: make(chan struct{})}
+	c := New(provider, time.Hour, time.Minute)
+
+	const callers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := c.Weather(context.Background(), 55.75, 37.61, weather.Params{})
+			errs <- err
+		}()
+	}
+
+	// Let every caller reach the cache before the upstream request returns.
+	for c.Stats()["weather"].Misses < callers {
+		time.Sleep(time.Millisecond)
+	}
+	close(provider.release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Weather() error = %v", err)
+		}
+	}
+	if got := provider.weatherCalls.Load(); got != 1 {
+		t.Errorf("got %d upstream calls, want 1", got)
+	}
+}
+
+func TestCachedProvider_CallerGivesUp(t *testing.T) {
+	provider := &mockProvider{release: make(chan struct{})}
+	c := New(provider, time.Hour, time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := c.Weather(ctx, 55.75, 37.61, weather.Params{})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Weather() error = %v, want context.Canceled", err)
+	}
+
+	// The upstream request still completes and fills the cache.
+	close(provider.release)
+	for {
+		_, err = c.Weather(context.Background(), 55.75, 37.61, weather.Params{})
+		if err != nil {
+			t.Fatalf("Weather() error = %v", err)
+		}
+		if c.Stats()["weather"].Hits > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if got := provider.weatherCalls.Load(); got != 1 {
+		t.Errorf("got %d upstream calls, want 1", got)
+	}
+}
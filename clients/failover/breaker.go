@@ -0,0 +1,119 @@
+package failover
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+type state int
+
+const (
+	closed   state = iota // requests go to the provider
+	open                  // the provider is skipped until the cooldown ends
+	halfOpen              // a single probe request decides whether to close
+)
+
+func (s state) String() string {
+	switch s {
+	case closed:
+		return "closed"
+	case open:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+type result int
+
+const (
+	succeeded result = iota
+	failed
+	// neutral is an answer that says nothing about the provider's health,
+	// e.g. data it doesn't offer or a caller that gave up.
+	neutral
+)
+
+// breaker opens after threshold consecutive outage errors and lets a probe
+// through once cooldown has passed.
+type breaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    state
+	failures int
+	openedAt time.Time
+	probing  bool
+	opens    int64
+	skipped  int64
+}
+
+// allow reports whether a request may be sent to the provider. In the
+// half-open state only one request at a time is let through.
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == open && b.now().Sub(b.openedAt) >= b.cooldown {
+		b.setState(halfOpen)
+	}
+
+	switch b.state {
+	case closed:
+		return true
+	case halfOpen:
+		if !b.probing {
+			b.probing = true
+			return true
+		}
+	}
+
+	b.skipped++
+	return false
+}
+
+// record updates the breaker with the result of an allowed request.
+func (b *breaker) record(res result) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == halfOpen {
+		b.probing = false
+	}
+
+	switch res {
+	case succeeded:
+		b.failures = 0
+		if b.state != closed {
+			b.setState(closed)
+		}
+	case failed:
+		b.failures++
+		if b.state == halfOpen || b.state == closed && b.failures >= b.threshold {
+			b.openedAt = b.now()
+			b.opens++
+			b.setState(open)
+		}
+	}
+}
+
+func (b *breaker) setState(s state) {
+	log.Printf("Weather provider %s: circuit %s -> %s", b.name, b.state, s)
+	b.state = s
+}
+
+func (b *breaker) stats() Stats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return Stats{
+		State:    b.state.String(),
+		Failures: b.failures,
+		Opens:    b.opens,
+		Skipped:  b.skipped,
+	}
+}
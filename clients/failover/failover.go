@@ -0,0 +1,179 @@
+// Package failover spreads requests over an ordered list of weather providers.
+// Each provider has a circuit breaker: after repeated outages it is skipped
+// and requests go to the next provider until a probe succeeds again.
+package failover
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"study/weatherbot/weather"
+	"time"
+)
+
+// ErrUnavailable is returned when the circuits of all providers are open.
+var ErrUnavailable = fmt.Errorf("all weather providers are unavailable: %w", weather.ErrUpstream)
+
+// Backend is a provider under the name its breaker is logged and reported as.
+type Backend struct {
+	Name     string
+	Provider weather.Provider
+}
+
+type Config struct {
+	// FailureThreshold is the number of consecutive outage errors that
+	// opens the circuit of a provider.
+	FailureThreshold int
+	// Cooldown is how long an open circuit stays open before a probe.
+	Cooldown time.Duration
+	// Timeout bounds a request to one provider so a hanging primary leaves
+	// time for the next one.
+	Timeout time.Duration
+}
+
+type backend struct {
+	name     string
+	provider weather.Provider
+	breaker  *breaker
+}
+
+// FailoverProvider sends each request to the first provider whose circuit
+// is not open and moves on to the next one on 5xx, 429, network errors and
+// timeouts. Other errors, like an unknown city, are returned as is.
+type FailoverProvider struct {
+	backends []*backend
+	timeout  time.Duration
+}
+
+func New(backends []Backend, cfg Config) *FailoverProvider {
+	p := &FailoverProvider{timeout: cfg.Timeout}
+	for _, b := range backends {
+		p.backends = append(p.backends, &backend{
+			name:     b.Name,
+			provider: b.Provider,
+			breaker: &breaker{
+				name:      b.Name,
+				threshold: cfg.FailureThreshold,
+				cooldown:  cfg.Cooldown,
+				now:       time.Now,
+			},
+		})
+	}
+	return p
+}
+
+func (p *FailoverProvider) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.Coordinate, error) {
+		return provider.Coordinates(ctx, city)
+	})
+}
+
+func (p *FailoverProvider) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) ([]weather.Coordinate, error) {
+		return provider.Cities(ctx, city)
+	})
+}
+
+func (p *FailoverProvider) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.Coordinate, error) {
+		return provider.ReverseGeocode(ctx, lat, lon)
+	})
+}
+
+func (p *FailoverProvider) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.Weather, error) {
+		return provider.Weather(ctx, lat, lon, params)
+	})
+}
+
+func (p *FailoverProvider) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.Forecast, error) {
+		return provider.Forecast(ctx, lat, lon, params)
+	})
+}
+
+func (p *FailoverProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) ([]weather.Alert, error) {
+		return provider.Alerts(ctx, lat, lon)
+	})
+}
+
+func (p *FailoverProvider) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.AirPollution, error) {
+		return provider.AirPollution(ctx, lat, lon)
+	})
+}
+
+func (p *FailoverProvider) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	return call(ctx, p, func(ctx context.Context, provider weather.Provider) (weather.Nowcast, error) {
+		return provider.Nowcast(ctx, lat, lon)
+	})
+}
+
+type Stats struct {
+	State    string `json:"state"`
+	Failures int    `json:"failures"` // consecutive outage errors
+	Opens    int64  `json:"opens"`    // times the circuit has opened
+	Skipped  int64  `json:"skipped"`  // requests sent elsewhere while open
+}
+
+// Stats reports the circuit state of each provider by name.
+func (p *FailoverProvider) Stats() map[string]Stats {
+	stats := make(map[string]Stats, len(p.backends))
+	for _, b := range p.backends {
+		stats[b.name] = b.breaker.stats()
+	}
+	return stats
+}
+
+// call tries the providers in order. Providers that don't offer the data are
+// passed over without counting against their circuit.
+func call[T any](ctx context.Context, p *FailoverProvider, f func(ctx context.Context, provider weather.Provider) (T, error)) (T, error) {
+	var zero T
+	err := ErrUnavailable
+
+	for _, b := range p.backends {
+		if !b.breaker.allow() {
+			continue
+		}
+
+		value, callErr := attempt(ctx, p.timeout, b.provider, f)
+		switch {
+		case callErr == nil:
+			b.breaker.record(succeeded)
+			return value, nil
+		case ctx.Err() != nil:
+			// The caller gave up, the provider is not to blame.
+			b.breaker.record(neutral)
+			return zero, callErr
+		case errors.Is(callErr, weather.ErrNotSupported):
+			b.breaker.record(neutral)
+		case isOutage(callErr):
+			b.breaker.record(failed)
+		default:
+			b.breaker.record(succeeded)
+			return zero, callErr
+		}
+		err = callErr
+	}
+
+	return zero, err
+}
+
+func attempt[T any](ctx context.Context, timeout time.Duration, provider weather.Provider, f func(ctx context.Context, provider weather.Provider) (T, error)) (T, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	return f(ctx, provider)
+}
+
+// isOutage reports whether err means the provider is down or overloaded
+// rather than that the request was wrong.
+func isOutage(err error) bool {
+	var netErr net.Error
+	return errors.Is(err, weather.ErrUpstream) || errors.Is(err, weather.ErrRateLimited) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.As(err, &netErr)
+}
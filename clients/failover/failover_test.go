@@ -0,0 +1,155 @@
+package failover
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"study/weatherbot/weather"
+	"testing"
+	"time"
+)
+
+type mockProvider struct {
+	weather.Provider
+	temp  float64
+	calls int
+	err   error
+	delay time.Duration
+}
+
+func (m *mockProvider) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	m.calls++
+	if m.delay > 0 {
+		select {
+		case <-time.After(m.delay):
+		case <-ctx.Done():
+			return weather.Weather{}, ctx.Err()
+		}
+	}
+	return weather.Weather{Temp: m.temp}, m.err
+}
+
+func (m *mockProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	m.calls++
+	return nil, weather.ErrNotSupported
+}
+
+func newTestProvider(primary *mockProvider, secondary *mockProvider) (*FailoverProvider, *time.Time) {
+	p := New([]Backend{
+		{Name: "primary", Provider: primary},
+		{Name: "secondary", Provider: secondary},
+	}, Config{FailureThreshold: 3, Cooldown: time.Minute, Timeout: 50 * time.Millisecond})
+
+	now := time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC)
+	for _, b := range p.backends {
+		b.breaker.now = func() time.Time { return now }
+	}
+	return p, &now
+}
+
+func TestFailoverProvider_Breaker(t *testing.T) {
+	primary := &mockProvider{temp: 1, err: &weather.StatusError{StatusCode: http.StatusBadGateway}}
+	secondary := &mockProvider{temp: 2}
+	p, now := newTestProvider(primary, secondary)
+
+	get := func() float64 {
+		t.Helper()
+		w, err := p.Weather(context.Background(), 55.75, 37.62, weather.Params{})
+		if err != nil {
+			t.Fatalf("Weather() error = %v", err)
+		}
+		return w.Temp
+	}
+
+	// Every failed request is answered by the secondary.
+	for range 3 {
+		if temp := get(); temp != 2 {
+			t.Fatalf("got weather from provider %v, want the secondary", temp)
+		}
+	}
+	if state := p.Stats()["primary"].State; state != "open" {
+		t.Fatalf("got primary circuit %s after 3 failures, want open", state)
+	}
+
+	// While open the primary is not asked at all.
+	get()
+	if primary.calls != 3 {
+		t.Errorf("got %d primary calls, want none while open", primary.calls-3)
+	}
+
+	// After the cooldown a failed probe opens the circuit again.
+	*now = now.Add(time.Minute)
+	get()
+	if primary.calls != 4 || p.Stats()["primary"].State != "open" {
+		t.Errorf("got %d primary calls and state %s, want a failed probe", primary.calls, p.Stats()["primary"].State)
+	}
+
+	// A successful probe closes it.
+	*now = now.Add(time.Minute)
+	primary.err = nil
+	if temp := get(); temp != 1 {
+		t.Errorf("got weather from provider %v, want the primary after recovery", temp)
+	}
+	stats := p.Stats()["primary"]
+	if stats.State != "closed" || stats.Opens != 2 || stats.Skipped != 1 {
+		t.Errorf("unexpected primary stats %+v", stats)
+	}
+}
+
+func TestFailoverProvider_Errors(t *testing.T) {
+	t.Run("Client errors are not failed over", func(t *testing.T) {
+		primary := &mockProvider{err: &weather.StatusError{StatusCode: http.StatusUnauthorized}}
+		secondary := &mockProvider{}
+		p, _ := newTestProvider(primary, secondary)
+
+		_, err := p.Weather(context.Background(), 0, 0, weather.Params{})
+		var statusErr *weather.StatusError
+		if !errors.As(err, &statusErr) || secondary.calls != 0 {
+			t.Errorf("got error %v and %d secondary calls, want the 401 returned", err, secondary.calls)
+		}
+	})
+
+	t.Run("Timeouts and rate limits are failed over", func(t *testing.T) {
+		for _, primary := range []*mockProvider{
+			{delay: time.Second},
+			{err: &weather.StatusError{StatusCode: http.StatusTooManyRequests}},
+		} {
+			secondary := &mockProvider{temp: 2}
+			p, _ := newTestProvider(primary, secondary)
+
+			w, err := p.Weather(context.Background(), 0, 0, weather.Params{})
+			if err != nil || w.Temp != 2 {
+				t.Errorf("got %v, %v, want the secondary's weather", w.Temp, err)
+			}
+			if failures := p.Stats()["primary"].Failures; failures != 1 {
+				t.Errorf("got %d primary failures, want 1", failures)
+			}
+		}
+	})
+
+	t.Run("Unsupported data", func(t *testing.T) {
+		primary, secondary := &mockProvider{}, &mockProvider{}
+		p, _ := newTestProvider(primary, secondary)
+
+		_, err := p.Alerts(context.Background(), 0, 0)
+		if !errors.Is(err, weather.ErrNotSupported) || primary.calls != 1 || secondary.calls != 1 {
+			t.Errorf("got error %v, want both providers asked", err)
+		}
+		if failures := p.Stats()["primary"].Failures; failures != 0 {
+			t.Errorf("got %d primary failures, want unsupported data not counted", failures)
+		}
+	})
+
+	t.Run("All circuits open", func(t *testing.T) {
+		outage := &weather.StatusError{StatusCode: http.StatusServiceUnavailable}
+		p, _ := newTestProvider(&mockProvider{err: outage}, &mockProvider{err: outage})
+
+		for range 3 {
+			p.Weather(context.Background(), 0, 0, weather.Params{})
+		}
+		_, err := p.Weather(context.Background(), 0, 0, weather.Params{})
+		if !errors.Is(err, ErrUnavailable) {
+			t.Errorf("got error %v, want ErrUnavailable", err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package openmeteo
+
+// condition describes a WMO weather interpretation code, Open-Meteo reports
+// no text of its own.
+type condition struct {
+	group string // group of parameters as OpenWeather names them
+	en    string
+	ru    string
+}
+
+var conditions = map[int]condition{
+	0:  {"Clear", "clear sky", "ясно"},
+	1:  {"Clouds", "mainly clear", "преимущественно ясно"},
+	2:  {"Clouds", "partly cloudy", "переменная облачность"},
+	3:  {"Clouds", "overcast", "пасмурно"},
+	45: {"Fog", "fog", "туман"},
+	48: {"Fog", "depositing rime fog", "туман с изморозью"},
+	51: {"Drizzle", "light drizzle", "слабая морось"},
+	53: {"Drizzle", "drizzle", "морось"},
+	55: {"Drizzle", "dense drizzle", "сильная морось"},
+	56: {"Drizzle", "light freezing drizzle", "слабая ледяная морось"},
+	57: {"Drizzle", "freezing drizzle", "ледяная морось"},
+	61: {"Rain", "light rain", "небольшой дождь"},
+	63: {"Rain", "rain", "дождь"},
+	65: {"Rain", "heavy rain", "сильный дождь"},
+	66: {"Rain", "light freezing rain", "слабый ледяной дождь"},
+	67: {"Rain", "freezing rain", "ледяной дождь"},
+	71: {"Snow", "light snow", "небольшой снег"},
+	73: {"Snow", "snow", "снег"},
+	75: {"Snow", "heavy snow", "сильный снег"},
+	77: {"Snow", "snow grains", "снежные зерна"},
+	80: {"Rain", "light rain showers", "небольшой ливень"},
+	81: {"Rain", "rain showers", "ливень"},
+	82: {"Rain", "violent rain showers", "сильный ливень"},
+	85: {"Snow", "light snow showers", "небольшой снегопад"},
+	86: {"Snow", "snow showers", "снегопад"},
+	95: {"Thunderstorm", "thunderstorm", "гроза"},
+	96: {"Thunderstorm", "thunderstorm with hail", "гроза с градом"},
+	99: {"Thunderstorm", "thunderstorm with heavy hail", "гроза с сильным градом"},
+}
+
+// describe returns the description of the code in lang, English unless lang
+// is Russian.
+func describe(code int, lang string) string {
+	c, ok := conditions[code]
+	if !ok {
+		return ""
+	}
+	if lang == "ru" {
+		return c.ru
+	}
+	return c.en
+}
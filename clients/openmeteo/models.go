@@ -0,0 +1,73 @@
+package openmeteo
+
+type GeocodingResponse struct {
+	Results []struct {
+		Name        string  `json:"name"`
+		Latitude    float64 `json:"latitude"`
+		Longitude   float64 `json:"longitude"`
+		CountryCode string  `json:"country_code"`
+		Admin1      string  `json:"admin1"`
+	} `json:"results"`
+}
+
+type WeatherResponse struct {
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+	Current          struct {
+		Time          int64   `json:"time"`
+		Temperature   float64 `json:"temperature_2m"`
+		Humidity      int     `json:"relative_humidity_2m"`
+		ApparentTemp  float64 `json:"apparent_temperature"`
+		Rain          float64 `json:"rain"`
+		Snowfall      float64 `json:"snowfall"`
+		WeatherCode   int     `json:"weather_code"`
+		CloudCover    int     `json:"cloud_cover"`
+		PressureMSL   float64 `json:"pressure_msl"`
+		WindSpeed     float64 `json:"wind_speed_10m"`
+		WindDirection int     `json:"wind_direction_10m"`
+		WindGusts     float64 `json:"wind_gusts_10m"`
+		Visibility    float64 `json:"visibility"`
+	} `json:"current"`
+	Daily struct {
+		TemperatureMax []float64 `json:"temperature_2m_max"`
+		TemperatureMin []float64 `json:"temperature_2m_min"`
+		Sunrise        []int64   `json:"sunrise"`
+		Sunset         []int64   `json:"sunset"`
+	} `json:"daily"`
+}
+
+type ForecastResponse struct {
+	UTCOffsetSeconds int `json:"utc_offset_seconds"`
+	Hourly           struct {
+		Time                     []int64   `json:"time"`
+		Temperature              []float64 `json:"temperature_2m"`
+		PrecipitationProbability []float64 `json:"precipitation_probability"`
+		WeatherCode              []int     `json:"weather_code"`
+		WindSpeed                []float64 `json:"wind_speed_10m"`
+		WindGusts                []float64 `json:"wind_gusts_10m"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                        []int64   `json:"time"`
+		WeatherCode                 []int     `json:"weather_code"`
+		TemperatureMax              []float64 `json:"temperature_2m_max"`
+		TemperatureMin              []float64 `json:"temperature_2m_min"`
+		PrecipitationProbabilityMax []float64 `json:"precipitation_probability_max"`
+	} `json:"daily"`
+}
+
+type AirQualityResponse struct {
+	Current struct {
+		Time            int64   `json:"time"`
+		EuropeanAQI     float64 `json:"european_aqi"`
+		PM10            float64 `json:"pm10"`
+		PM25            float64 `json:"pm2_5"`
+		CarbonMonoxide  float64 `json:"carbon_monoxide"`
+		NitrogenDioxide float64 `json:"nitrogen_dioxide"`
+		SulphurDioxide  float64 `json:"sulphur_dioxide"`
+		Ozone           float64 `json:"ozone"`
+		Ammonia         float64 `json:"ammonia"`
+	} `json:"current"`
+	Hourly struct {
+		Time        []int64   `json:"time"`
+		EuropeanAQI []float64 `json:"european_aqi"`
+	} `json:"hourly"`
+}
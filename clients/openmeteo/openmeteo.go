@@ -0,0 +1,412 @@
+// Package openmeteo is a weather provider backed by Open-Meteo, which needs
+// no API key. It offers no official warnings, no reverse geocoding and no
+// precipitation nowcast.
+package openmeteo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"study/weatherbot/weather"
+	"time"
+)
+
+const (
+	currentVariables = "temperature_2m,relative_humidity_2m,apparent_temperature,rain,snowfall,weather_code," +
+		"cloud_cover,pressure_msl,wind_speed_10m,wind_direction_10m,wind_gusts_10m,visibility"
+	hourlyVariables = "temperature_2m,precipitation_probability,weather_code,wind_speed_10m,wind_gusts_10m"
+	dailyVariables  = "weather_code,temperature_2m_max,temperature_2m_min,precipitation_probability_max"
+	airVariables    = "european_aqi,pm10,pm2_5,carbon_monoxide,nitrogen_dioxide,sulphur_dioxide,ozone,ammonia"
+
+	// forecastDays matches the 5 day forecast of OpenWeather.
+	forecastDays = 5
+	// maxVisibility is the limit OpenWeather reports visibility up to.
+	maxVisibility = 10000
+
+	// defaultTimeout bounds a request of the default HTTP client.
+	defaultTimeout = 10 * time.Second
+
+	// mmPerCm converts the snowfall Open-Meteo reports in cm to mm, the unit
+	// OpenWeather reports snow in and weather.snow shows per hour.
+	mmPerCm = 10
+)
+
+// Paths of the endpoints on their hosts.
+const (
+	geoPath      = "/v1/search"
+	forecastPath = "/v1/forecast"
+	airPath      = "/v1/air-quality"
+)
+
+type OpenMeteoClient struct {
+	geoURL      string // https://geocoding-api.open-meteo.com/v1/search
+	forecastURL string // https://api.open-meteo.com/v1/forecast
+	airURL      string // https://air-quality-api.open-meteo.com/v1/air-quality
+	httpClient  *http.Client
+	userAgent   string
+	timeout     time.Duration // bounds each request, zero leaves it to ctx
+}
+
+// Option configures the client created by New.
+type Option func(*OpenMeteoClient)
+
+// WithHTTPClient sends requests with c instead of the default client, which
+// gives up on a request after 10 seconds.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *OpenMeteoClient) {
+		o.httpClient = c
+	}
+}
+
+// WithBaseURLs points the client at other hosts serving the geocoding, the
+// forecast and the air quality APIs of Open-Meteo, e.g. a mock in staging.
+// The same URL may be given for all three.
+func WithBaseURLs(geo string, forecast string, air string) Option {
+	return func(o *OpenMeteoClient) {
+		o.geoURL = strings.TrimSuffix(geo, "/") + geoPath
+		o.forecastURL = strings.TrimSuffix(forecast, "/") + forecastPath
+		o.airURL = strings.TrimSuffix(air, "/") + airPath
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *OpenMeteoClient) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithTimeout bounds each request.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *OpenMeteoClient) {
+		o.timeout = timeout
+	}
+}
+
+func New(opts ...Option) *OpenMeteoClient {
+	o := &OpenMeteoClient{
+		geoURL:      "https://geocoding-api.open-meteo.com" + geoPath,
+		forecastURL: "https://api.open-meteo.com" + forecastPath,
+		airURL:      "https://air-quality-api.open-meteo.com" + airPath,
+		httpClient:  &http.Client{Timeout: defaultTimeout},
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o OpenMeteoClient) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	cities, err := o.Cities(ctx, city)
+	if err != nil {
+		return weather.Coordinate{}, err
+	}
+
+	return cities[0], nil
+}
+
+// Cities returns up to five places matching the city name, most relevant
+// first. Results that differ only in coordinates are reported once.
+func (o OpenMeteoClient) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	q := url.Values{"name": {city}, "count": {"5"}, "language": {"en"}, "format": {"json"}}
+
+	var geocodingResponse GeocodingResponse
+	err := o.getJSON(ctx, o.geoURL, q, &geocodingResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error get Coordinates: %w", err)
+	}
+
+	if len(geocodingResponse.Results) == 0 {
+		return nil, weather.ErrCityNotFound
+	}
+
+	cities := make([]weather.Coordinate, 0, len(geocodingResponse.Results))
+	seen := make(map[weather.Coordinate]bool)
+	for _, r := range geocodingResponse.Results {
+		key := weather.Coordinate{Name: r.Name, Country: r.CountryCode, State: r.Admin1}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		cities = append(cities, weather.Coordinate{
+			Name:    r.Name,
+			Country: r.CountryCode,
+			State:   r.Admin1,
+			Lat:     r.Latitude,
+			Lon:     r.Longitude,
+		})
+	}
+
+	return cities, nil
+}
+
+// ReverseGeocode is not offered by Open-Meteo.
+func (o OpenMeteoClient) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	return weather.Coordinate{}, weather.ErrNotSupported
+}
+
+// Nowcast is not offered by Open-Meteo.
+func (o OpenMeteoClient) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	return weather.Nowcast{}, weather.ErrNotSupported
+}
+
+func (o OpenMeteoClient) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	q := query(lat, lon, params)
+	q.Set("current", currentVariables)
+	q.Set("daily", "temperature_2m_max,temperature_2m_min,sunrise,sunset")
+	q.Set("forecast_days", "1")
+
+	var weatherResponse WeatherResponse
+	err := o.getJSON(ctx, o.forecastURL, q, &weatherResponse)
+	if err != nil {
+		return weather.Weather{}, fmt.Errorf("error get weather: %w", err)
+	}
+
+	loc := time.FixedZone("", weatherResponse.UTCOffsetSeconds)
+	c := weatherResponse.Current
+	condition := conditions[c.WeatherCode]
+
+	current := weather.Weather{
+		Time:        time.Unix(c.Time, 0).In(loc),
+		Temp:        temperature(params.Units, c.Temperature),
+		FeelsLike:   temperature(params.Units, c.ApparentTemp),
+		Pressure:    int(math.Round(c.PressureMSL)),
+		Humidity:    c.Humidity,
+		WindSpeed:   c.WindSpeed,
+		WindDeg:     c.WindDirection,
+		WindGust:    c.WindGusts,
+		Clouds:      c.CloudCover,
+		Visibility:  min(int(c.Visibility), maxVisibility),
+		Rain1h:      c.Rain,
+		Snow1h:      c.Snowfall * mmPerCm,
+		ConditionID: c.WeatherCode,
+		Condition:   condition.group,
+		Description: describe(c.WeatherCode, params.Lang),
+		Timezone:    weatherResponse.UTCOffsetSeconds,
+	}
+
+	daily := weatherResponse.Daily
+	if len(daily.TemperatureMin) > 0 && len(daily.TemperatureMax) > 0 {
+		current.TempMin = temperature(params.Units, daily.TemperatureMin[0])
+		current.TempMax = temperature(params.Units, daily.TemperatureMax[0])
+	}
+	if len(daily.Sunrise) > 0 && len(daily.Sunset) > 0 {
+		current.Sunrise = time.Unix(daily.Sunrise[0], 0).In(loc)
+		current.Sunset = time.Unix(daily.Sunset[0], 0).In(loc)
+	}
+
+	return current, nil
+}
+
+// Forecast returns the 5 day hourly forecast for the given point together
+// with the daily summaries Open-Meteo computes for the local days of that
+// point.
+func (o OpenMeteoClient) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	q := query(lat, lon, params)
+	q.Set("hourly", hourlyVariables)
+	q.Set("daily", dailyVariables)
+	q.Set("forecast_days", strconv.Itoa(forecastDays))
+
+	var forecastResponse ForecastResponse
+	err := o.getJSON(ctx, o.forecastURL, q, &forecastResponse)
+	if err != nil {
+		return weather.Forecast{}, fmt.Errorf("error get forecast: %w", err)
+	}
+
+	loc := time.FixedZone("", forecastResponse.UTCOffsetSeconds)
+
+	hourly := forecastResponse.Hourly
+	if len(hourly.Temperature) != len(hourly.Time) || len(hourly.PrecipitationProbability) != len(hourly.Time) ||
+		len(hourly.WeatherCode) != len(hourly.Time) || len(hourly.WindSpeed) != len(hourly.Time) ||
+		len(hourly.WindGusts) != len(hourly.Time) {
+		return weather.Forecast{}, fmt.Errorf("error get forecast: hourly series of different lengths")
+	}
+
+	hours := make([]weather.ForecastHour, 0, len(hourly.Time))
+	for i, t := range hourly.Time {
+		temp := temperature(params.Units, hourly.Temperature[i])
+		hours = append(hours, weather.ForecastHour{
+			Time:        time.Unix(t, 0).In(loc),
+			Temp:        temp,
+			TempMin:     temp,
+			TempMax:     temp,
+			Pop:         hourly.PrecipitationProbability[i] / 100,
+			WindSpeed:   hourly.WindSpeed[i],
+			WindGust:    hourly.WindGusts[i],
+			ConditionID: hourly.WeatherCode[i],
+			Description: describe(hourly.WeatherCode[i], params.Lang),
+		})
+	}
+
+	daily := forecastResponse.Daily
+	if len(daily.TemperatureMin) != len(daily.Time) || len(daily.TemperatureMax) != len(daily.Time) ||
+		len(daily.PrecipitationProbabilityMax) != len(daily.Time) || len(daily.WeatherCode) != len(daily.Time) {
+		return weather.Forecast{}, fmt.Errorf("error get forecast: daily series of different lengths")
+	}
+
+	days := make([]weather.DailyForecast, 0, len(daily.Time))
+	for i, t := range daily.Time {
+		days = append(days, weather.DailyForecast{
+			Date:        time.Unix(t, 0).In(loc),
+			TempMin:     temperature(params.Units, daily.TemperatureMin[i]),
+			TempMax:     temperature(params.Units, daily.TemperatureMax[i]),
+			Pop:         daily.PrecipitationProbabilityMax[i] / 100,
+			ConditionID: daily.WeatherCode[i],
+			Description: describe(daily.WeatherCode[i], params.Lang),
+		})
+	}
+
+	return weather.Forecast{
+		Timezone: forecastResponse.UTCOffsetSeconds,
+		Step:     time.Hour,
+		Hours:    hours,
+		Days:     days,
+	}, nil
+}
+
+// Alerts is not offered by Open-Meteo.
+func (o OpenMeteoClient) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	return nil, weather.ErrNotSupported
+}
+
+// AirPollution returns the current air quality at the point together with
+// the hourly forecast for the next days. The European AQI is mapped to the
+// five levels of OpenWeather.
+func (o OpenMeteoClient) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	q := point(lat, lon)
+	q.Set("current", airVariables)
+	q.Set("hourly", "european_aqi")
+	q.Set("forecast_days", "2")
+	q.Set("timeformat", "unixtime")
+
+	var airResponse AirQualityResponse
+	err := o.getJSON(ctx, o.airURL, q, &airResponse)
+	if err != nil {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution: %w", err)
+	}
+	if airResponse.Current.Time == 0 {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution: empty response")
+	}
+
+	c := airResponse.Current
+	air := weather.AirPollution{
+		Current: weather.AirQuality{
+			Time: time.Unix(c.Time, 0).UTC(),
+			AQI:  airQualityIndex(c.EuropeanAQI),
+			CO:   c.CarbonMonoxide,
+			NO2:  c.NitrogenDioxide,
+			O3:   c.Ozone,
+			SO2:  c.SulphurDioxide,
+			PM25: c.PM25,
+			PM10: c.PM10,
+			NH3:  c.Ammonia,
+		},
+	}
+
+	hourly := airResponse.Hourly
+	if len(hourly.EuropeanAQI) != len(hourly.Time) {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution: hourly series of different lengths")
+	}
+	air.Forecast = make([]weather.AirQuality, 0, len(hourly.Time))
+	for i, t := range hourly.Time {
+		air.Forecast = append(air.Forecast, weather.AirQuality{
+			Time: time.Unix(t, 0).UTC(),
+			AQI:  airQualityIndex(hourly.EuropeanAQI[i]),
+		})
+	}
+
+	return air, nil
+}
+
+// airQualityIndex maps the European AQI, bands of 20 from good to very poor,
+// to an index from 1 to 5.
+func airQualityIndex(europeanAQI float64) int {
+	switch {
+	case europeanAQI <= 20:
+		return 1
+	case europeanAQI <= 40:
+		return 2
+	case europeanAQI <= 60:
+		return 3
+	case europeanAQI <= 80:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// point returns the query parameters for the coordinates.
+func point(lat float64, lon float64) url.Values {
+	return url.Values{
+		"latitude":  {strconv.FormatFloat(lat, 'f', 6, 64)},
+		"longitude": {strconv.FormatFloat(lon, 'f', 6, 64)},
+	}
+}
+
+// query returns the query parameters for the coordinates and the user
+// preferences. Times are requested as Unix time, the local zone is applied
+// from the UTC offset.
+func query(lat float64, lon float64, p weather.Params) url.Values {
+	temperatureUnit, windSpeedUnit := "celsius", "ms"
+	if p.Units == weather.Imperial {
+		temperatureUnit, windSpeedUnit = "fahrenheit", "mph"
+	}
+
+	q := point(lat, lon)
+	q.Set("temperature_unit", temperatureUnit)
+	q.Set("wind_speed_unit", windSpeedUnit)
+	q.Set("timezone", "auto")
+	q.Set("timeformat", "unixtime")
+	return q
+}
+
+// temperature converts a temperature in the requested unit to units,
+// Open-Meteo has no Kelvin.
+func temperature(units weather.Units, v float64) float64 {
+	if units == weather.Standard {
+		return v + 273.15
+	}
+	return v
+}
+
+func (o OpenMeteoClient) getJSON(ctx context.Context, endpoint string, q url.Values, v any) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint+"?"+q.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+	if o.userAgent != "" {
+		req.Header.Set("User-Agent", o.userAgent)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("error do request: %w: %w", weather.ErrUpstream, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return &weather.StatusError{StatusCode: resp.StatusCode}
+	}
+
+	err = json.NewDecoder(resp.Body).Decode(v)
+	if err != nil {
+		log.Println(err)
+		return fmt.Errorf("error unmarshal response: %w", err)
+	}
+
+	return nil
+}
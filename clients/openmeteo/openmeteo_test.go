@@ -0,0 +1,275 @@
+package openmeteo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"study/weatherbot/weather"
+	"testing"
+	"time"
+)
+
+func TestOpenMeteoClient_Cities(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("name") {
+		case "Nizhny Novgorod":
+			w.Write([]byte(`{"results": [
+				{"name": "Nizhniy Novgorod", "latitude": 56.3287, "longitude": 44.002, "country_code": "RU", "admin1": "Nizhny Novgorod Oblast"},
+				{"name": "Nizhniy Novgorod", "latitude": 56.33, "longitude": 44.0, "country_code": "RU", "admin1": "Nizhny Novgorod Oblast"}
+			]}`))
+		case "NotFound":
+			w.Write([]byte(`{"generationtime_ms": 0.5}`))
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(WithBaseURLs(server.URL, server.URL, server.URL))
+
+	cities, err := client.Cities(context.Background(), "Nizhny Novgorod")
+	if err != nil {
+		t.Fatalf("Cities() error = %v", err)
+	}
+	if len(cities) != 1 {
+		t.Fatalf("got %d cities, want duplicates reported once", len(cities))
+	}
+	want := weather.Coordinate{Name: "Nizhniy Novgorod", Country: "RU", State: "Nizhny Novgorod Oblast", Lat: 56.3287, Lon: 44.002}
+	if cities[0] != want {
+		t.Errorf("got %+v, want %+v", cities[0], want)
+	}
+
+	_, err = client.Coordinates(context.Background(), "NotFound")
+	if !errors.Is(err, weather.ErrCityNotFound) {
+		t.Errorf("got error %v, want ErrCityNotFound", err)
+	}
+
+	_, err = client.Cities(context.Background(), "Error")
+	if err == nil {
+		t.Error("got no error for a server error")
+	}
+}
+
+func TestOpenMeteoClient_Weather(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("latitude") != "55.755800" || q.Get("temperature_unit") != "fahrenheit" || q.Get("wind_speed_unit") != "mph" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// 2025-12-15 10:00 UTC is 13:00 in UTC+3, sunrise is at 08:55 and
+		// sunset at 16:00 local time.
+		w.Write([]byte(`{
+			"utc_offset_seconds": 10800,
+			"current": {"time": 1765792800, "temperature_2m": 28.4, "relative_humidity_2m": 86,
+				"apparent_temperature": 21.2, "rain": 0, "snowfall": 0.3, "weather_code": 73, "cloud_cover": 100,
+				"pressure_msl": 1012.6, "wind_speed_10m": 8.1, "wind_direction_10m": 225, "wind_gusts_10m": 17.9,
+				"visibility": 24140},
+			"daily": {"temperature_2m_max": [30.2], "temperature_2m_min": [24.8],
+				"sunrise": [1765778100], "sunset": [1765803600]}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(WithBaseURLs(server.URL, server.URL, server.URL))
+
+	_, err := client.Weather(context.Background(), 0, 0, weather.Params{Units: weather.Imperial})
+	if err == nil {
+		t.Error("Weather() error = nil, want error")
+	}
+
+	got, err := client.Weather(context.Background(), 55.7558, 37.6173, weather.Params{Lang: "ru", Units: weather.Imperial})
+	if err != nil {
+		t.Fatalf("Weather() error = %v", err)
+	}
+	if got.Time.Format("15:04") != "13:00" || got.Sunrise.Format("15:04") != "08:55" || got.Sunset.Format("15:04") != "16:00" {
+		t.Errorf("got local times %v, %v, %v, want 13:00, 08:55, 16:00",
+			got.Time.Format("15:04"), got.Sunrise.Format("15:04"), got.Sunset.Format("15:04"))
+	}
+	got.Time, got.Sunrise, got.Sunset = time.Time{}, time.Time{}, time.Time{}
+
+	want := weather.Weather{
+		Temp:        28.4,
+		FeelsLike:   21.2,
+		TempMin:     24.8,
+		TempMax:     30.2,
+		Pressure:    1013,
+		Humidity:    86,
+		WindSpeed:   8.1,
+		WindDeg:     225,
+		WindGust:    17.9,
+		Clouds:      100,
+		Visibility:  10000,
+		Snow1h:      3,
+		ConditionID: 73,
+		Condition:   "Snow",
+		Description: "снег",
+		Timezone:    10800,
+	}
+	if got != want {
+		t.Errorf("got Weather %+v, want %+v", got, want)
+	}
+}
+
+func TestOpenMeteoClient_Forecast(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/forecast", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("forecast_days") != "5" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		// Hours are 2025-12-15 21:00 and 22:00 in UTC+3, days start at local
+		// midnight of the 15th and the 16th.
+		w.Write([]byte(`{
+			"utc_offset_seconds": 10800,
+			"hourly": {"time": [1765821600, 1765825200], "temperature_2m": [-1, -2],
+				"precipitation_probability": [80, null], "weather_code": [71, 3],
+				"wind_speed_10m": [4, 5], "wind_gusts_10m": [9, 12]},
+			"daily": {"time": [1765746000, 1765832400], "weather_code": [71, 3],
+				"temperature_2m_max": [0, -3], "temperature_2m_min": [-2, -7],
+				"precipitation_probability_max": [80, 30]}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(WithBaseURLs(server.URL, server.URL, server.URL))
+
+	forecast, err := client.Forecast(context.Background(), 55.7558, 37.6173, weather.Params{Units: weather.Standard})
+	if err != nil {
+		t.Fatalf("Forecast() error = %v", err)
+	}
+
+	if forecast.Step != time.Hour || len(forecast.Hours) != 2 {
+		t.Fatalf("got %d hours of %v, want 2 of an hour", len(forecast.Hours), forecast.Step)
+	}
+	if hour := forecast.Hours[1]; hour.Time.Format("15:04") != "22:00" || hour.Pop != 0 || hour.WindGust != 12 {
+		t.Errorf("unexpected hour %+v", hour)
+	}
+	if temp := forecast.Hours[0].Temp; temp != 272.15 {
+		t.Errorf("got Temp %v, want 272.15 K", temp)
+	}
+
+	if len(forecast.Days) != 2 {
+		t.Fatalf("got %d days, want 2", len(forecast.Days))
+	}
+	for i, day := range forecast.Days {
+		if day.Date.Day() != 15+i || day.Date.Hour() != 0 {
+			t.Errorf("day %d: got date %v, want local midnight of the %dth", i, day.Date, 15+i)
+		}
+	}
+	if day := forecast.Days[1]; day.Pop != 0.3 || day.Description != "overcast" {
+		t.Errorf("got day %+v, want Pop 0.3 and overcast", day)
+	}
+}
+
+func TestOpenMeteoClient_AirPollution(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/air-quality", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{
+			"current": {"time": 1765792800, "european_aqi": 47, "pm10": 30.1, "pm2_5": 21.4,
+				"carbon_monoxide": 310, "nitrogen_dioxide": 28.5, "sulphur_dioxide": 6.2, "ozone": 40, "ammonia": 1.1},
+			"hourly": {"time": [1765792800, 1765796400], "european_aqi": [47, 85]}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New(WithBaseURLs(server.URL, server.URL, server.URL))
+
+	air, err := client.AirPollution(context.Background(), 55.7558, 37.6173)
+	if err != nil {
+		t.Fatalf("AirPollution() error = %v", err)
+	}
+
+	want := weather.AirQuality{
+		Time: time.Unix(1765792800, 0).UTC(),
+		AQI:  3,
+		CO:   310,
+		NO2:  28.5,
+		O3:   40,
+		SO2:  6.2,
+		PM25: 21.4,
+		PM10: 30.1,
+		NH3:  1.1,
+	}
+	if air.Current != want {
+		t.Errorf("got %+v, want %+v", air.Current, want)
+	}
+	if len(air.Forecast) != 2 || air.Forecast[1].AQI != 5 {
+		t.Errorf("got forecast %+v, want 2 hours ending very poor", air.Forecast)
+	}
+}
+
+func TestOpenMeteoClient_NotSupported(t *testing.T) {
+	client := New()
+
+	_, err := client.ReverseGeocode(context.Background(), 55.7558, 37.6173)
+	if !errors.Is(err, weather.ErrNotSupported) {
+		t.Errorf("ReverseGeocode() error = %v, want ErrNotSupported", err)
+	}
+	_, err = client.Alerts(context.Background(), 55.7558, 37.6173)
+	if !errors.Is(err, weather.ErrNotSupported) {
+		t.Errorf("Alerts() error = %v, want ErrNotSupported", err)
+	}
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestOpenMeteoClient_Options(t *testing.T) {
+	if New().httpClient.Timeout == 0 {
+		t.Error("default HTTP client has no timeout")
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/search", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "weatherbot-test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("name") == "Slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(`{"results": [{"name": "Moscow", "latitude": 55.75, "longitude": 37.62, "country_code": "RU"}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := New(
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithUserAgent("weatherbot-test"),
+		WithTimeout(20*time.Millisecond),
+		WithBaseURLs(server.URL+"/", server.URL, server.URL),
+	)
+
+	coord, err := client.Coordinates(context.Background(), "Moscow")
+	if err != nil || coord.Name != "Moscow" {
+		t.Fatalf("got %q, %v, want Moscow", coord.Name, err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("got %d requests through the custom client, want 1", transport.requests)
+	}
+
+	_, err = client.Coordinates(context.Background(), "Slow")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the request timeout", err)
+	}
+}
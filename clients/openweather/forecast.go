@@ -0,0 +1,43 @@
+package openweather
+
+import (
+	"study/weatherbot/weather"
+	"time"
+)
+
+// dailySummaries folds 3-hour forecast slots into one summary per local
+// calendar day. Slot times must already be in the location's zone so that
+// day boundaries line up with local midnight rather than UTC.
+func dailySummaries(hours []weather.ForecastHour) []weather.DailyForecast {
+	var days []weather.DailyForecast
+	var conditions map[int]int
+
+	for _, hour := range hours {
+		y, m, d := hour.Time.Date()
+		date := time.Date(y, m, d, 0, 0, 0, 0, hour.Time.Location())
+
+		if len(days) == 0 || !days[len(days)-1].Date.Equal(date) {
+			days = append(days, weather.DailyForecast{
+				Date:    date,
+				TempMin: hour.TempMin,
+				TempMax: hour.TempMax,
+			})
+			conditions = make(map[int]int)
+		}
+
+		day := &days[len(days)-1]
+		day.TempMin = min(day.TempMin, hour.TempMin)
+		day.TempMax = max(day.TempMax, hour.TempMax)
+		day.Pop = max(day.Pop, hour.Pop)
+
+		// The day's condition is the one reported by most of its slots,
+		// the earliest one wins a tie.
+		conditions[hour.ConditionID]++
+		if conditions[hour.ConditionID] > conditions[day.ConditionID] {
+			day.ConditionID = hour.ConditionID
+			day.Description = hour.Description
+		}
+	}
+
+	return days
+}
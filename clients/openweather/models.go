@@ -1,23 +1,146 @@
 package openweather
 
+import (
+	"study/weatherbot/weather"
+	"time"
+)
+
 type CoordinateResponse struct {
-	Name string  `json:"name"`
-	Lat  float64 `json:"lat"`
-	Lon  float64 `json:"lon"`
+	Name    string  `json:"name"`
+	Lat     float64 `json:"lat"`
+	Lon     float64 `json:"lon"`
+	Country string  `json:"country"`
+	State   string  `json:"state"`
+}
+
+type WeatherResponse struct {
+	Name    string `json:"name"`
+	Dt      int64  `json:"dt"`
+	Weather []struct {
+		ID          int    `json:"id"`
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		TempMin   float64 `json:"temp_min"`
+		TempMax   float64 `json:"temp_max"`
+		Pressure  int     `json:"pressure"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Visibility int `json:"visibility"`
+	Wind       struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+		Gust  float64 `json:"gust"`
+	} `json:"wind"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	Rain struct {
+		OneHour float64 `json:"1h"`
+	} `json:"rain"`
+	Snow struct {
+		OneHour float64 `json:"1h"`
+	} `json:"snow"`
+	Sys struct {
+		Country string `json:"country"`
+		Sunrise int64  `json:"sunrise"`
+		Sunset  int64  `json:"sunset"`
+	} `json:"sys"`
+	Timezone int `json:"timezone"`
 }
 
-type Coordinate struct {
-	Name string
-	Lat  float64
-	Lon  float64
+type ForecastResponse struct {
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp    float64 `json:"temp"`
+			TempMin float64 `json:"temp_min"`
+			TempMax float64 `json:"temp_max"`
+		} `json:"main"`
+		Weather []struct {
+			ID          int    `json:"id"`
+			Description string `json:"description"`
+		} `json:"weather"`
+		Wind struct {
+			Speed float64 `json:"speed"`
+			Gust  float64 `json:"gust"`
+		} `json:"wind"`
+		Pop float64 `json:"pop"`
+	} `json:"list"`
+	City struct {
+		Name     string `json:"name"`
+		Timezone int    `json:"timezone"`
+	} `json:"city"`
 }
 
-type WeatherResponse struct {
+type OneCallResponse struct {
+	TimezoneOffset int `json:"timezone_offset"`
+	Alerts         []struct {
+		SenderName  string   `json:"sender_name"`
+		Event       string   `json:"event"`
+		Start       int64    `json:"start"`
+		End         int64    `json:"end"`
+		Description string   `json:"description"`
+		Tags        []string `json:"tags"`
+	} `json:"alerts"`
+}
+
+// NowcastResponse is the One Call response with the minutely and hourly
+// blocks only.
+type NowcastResponse struct {
+	TimezoneOffset int `json:"timezone_offset"`
+	Minutely       []struct {
+		Dt            int64   `json:"dt"`
+		Precipitation float64 `json:"precipitation"` // mm/h
+	} `json:"minutely"`
+	Hourly []struct {
+		Dt   int64   `json:"dt"`
+		Pop  float64 `json:"pop"`
+		Rain struct {
+			OneHour float64 `json:"1h"`
+		} `json:"rain"`
+		Snow struct {
+			OneHour float64 `json:"1h"`
+		} `json:"snow"`
+	} `json:"hourly"`
+}
+
+type AirPollutionResponse struct {
+	List []AirPollutionItem `json:"list"`
+}
+
+type AirPollutionItem struct {
+	Dt   int64 `json:"dt"`
 	Main struct {
-		Temp float64 `json:"temp"`
-	} `json: "main"`
+		AQI int `json:"aqi"`
+	} `json:"main"`
+	Components struct {
+		CO   float64 `json:"co"`
+		NO   float64 `json:"no"`
+		NO2  float64 `json:"no2"`
+		O3   float64 `json:"o3"`
+		SO2  float64 `json:"so2"`
+		PM25 float64 `json:"pm2_5"`
+		PM10 float64 `json:"pm10"`
+		NH3  float64 `json:"nh3"`
+	} `json:"components"`
 }
 
-type Weather struct {
-	Temp float64
+func (i AirPollutionItem) airQuality() weather.AirQuality {
+	return weather.AirQuality{
+		Time: time.Unix(i.Dt, 0).UTC(),
+		AQI:  i.Main.AQI,
+		CO:   i.Components.CO,
+		NO:   i.Components.NO,
+		NO2:  i.Components.NO2,
+		O3:   i.Components.O3,
+		SO2:  i.Components.SO2,
+		PM25: i.Components.PM25,
+		PM10: i.Components.PM10,
+		NH3:  i.Components.NH3,
+	}
 }
@@ -6,89 +6,477 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"math/rand/v2"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"study/weatherbot/weather"
+	"time"
 )
 
-var ErrCityNotFound = errors.New("city not found")
+const (
+	DefaultBaseURL = "https://api.openweathermap.org"
+
+	weatherPath  = "/data/2.5/weather"
+	forecastPath = "/data/2.5/forecast"
+	oneCallPath  = "/data/3.0/onecall"
+	airPath      = "/data/2.5/air_pollution"
+	geoPath      = "/geo/1.0/direct"
+	reversePath  = "/geo/1.0/reverse"
+
+	defaultMaxAttempts = 3
+	defaultRetryBase   = 200 * time.Millisecond
+)
 
 type OpenWeatherClient struct {
-	apiKey string
-	apiURL string // https://api.openweathermap.org/data/2.5/weather
-	geoURL string // http://api.openweathermap.org/geo/1.0/direct
+	apiKey     string
+	baseURL    string
+	httpClient *http.Client
+	userAgent  string
+	timeout    time.Duration // bounds each request, zero leaves it to ctx
+
+	maxAttempts int           // requests made for one call, including retries
+	retryBase   time.Duration // wait before the first retry
+}
+
+// Option configures the client created by New.
+type Option func(*OpenWeatherClient)
+
+// WithHTTPClient sends requests with c instead of http.DefaultClient, e.g.
+// to go through a proxy.
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *OpenWeatherClient) {
+		o.httpClient = c
+	}
+}
+
+// WithBaseURL points the client at another host serving the OpenWeather
+// API, e.g. a mock in staging.
+func WithBaseURL(baseURL string) Option {
+	return func(o *OpenWeatherClient) {
+		o.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithUserAgent sets the User-Agent header of the requests.
+func WithUserAgent(userAgent string) Option {
+	return func(o *OpenWeatherClient) {
+		o.userAgent = userAgent
+	}
+}
+
+// WithTimeout bounds each request, retries get a timeout of their own.
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *OpenWeatherClient) {
+		o.timeout = timeout
+	}
 }
 
-func New(apiKey string) *OpenWeatherClient {
-	return &OpenWeatherClient{
-		apiKey: apiKey,
-		apiURL: "https://api.openweathermap.org/data/2.5/weather",
-		geoURL: "http://api.openweathermap.org/geo/1.0/direct",
+// WithRetry sets how many requests a call makes at most and the wait before
+// the first retry, which doubles with every next one.
+func WithRetry(maxAttempts int, base time.Duration) Option {
+	return func(o *OpenWeatherClient) {
+		o.maxAttempts = max(1, maxAttempts)
+		o.retryBase = base
 	}
 }
 
-func (o OpenWeatherClient) Coordinates(ctx context.Context, city string) (Coordinate, error) {
-	url := fmt.Sprintf("%s?q=%s&limit=5&appid=%s", o.geoURL, city, o.apiKey)
+func New(apiKey string, opts ...Option) *OpenWeatherClient {
+	o := &OpenWeatherClient{
+		apiKey:      apiKey,
+		baseURL:     DefaultBaseURL,
+		httpClient:  http.DefaultClient,
+		maxAttempts: defaultMaxAttempts,
+		retryBase:   defaultRetryBase,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+func (o OpenWeatherClient) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	cities, err := o.Cities(ctx, city)
 	if err != nil {
-		return Coordinate{}, fmt.Errorf("error creating request: %w", err)
+		return weather.Coordinate{}, err
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	return cities[0], nil
+}
+
+// Cities returns up to five places matching the city name, most relevant
+// first. Results that differ only in coordinates are reported once.
+func (o OpenWeatherClient) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	q := url.Values{"q": {city}, "limit": {"5"}}
+
+	var coordinatesResponse []CoordinateResponse
+	err := o.getJSON(ctx, geoPath, q, &coordinatesResponse)
 	if err != nil {
-		log.Println(err)
-		return Coordinate{}, fmt.Errorf("error get Coordinates: %w", err)
+		return nil, fmt.Errorf("error get Coordinates: %w", err)
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != 200 {
-		return Coordinate{}, fmt.Errorf("error fail get Coordinates: %d", resp.StatusCode)
+	if len(coordinatesResponse) == 0 {
+		return nil, weather.ErrCityNotFound
 	}
 
+	cities := make([]weather.Coordinate, 0, len(coordinatesResponse))
+	seen := make(map[weather.Coordinate]bool)
+	for _, c := range coordinatesResponse {
+		key := weather.Coordinate{Name: c.Name, Country: c.Country, State: c.State}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		cities = append(cities, weather.Coordinate{
+			Name:    c.Name,
+			Country: c.Country,
+			State:   c.State,
+			Lat:     c.Lat,
+			Lon:     c.Lon,
+		})
+	}
+
+	return cities, nil
+}
+
+// ReverseGeocode returns the place nearest to the given point.
+func (o OpenWeatherClient) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	q := point(lat, lon)
+	q.Set("limit", "1")
+
 	var coordinatesResponse []CoordinateResponse
-	err = json.NewDecoder(resp.Body).Decode(&coordinatesResponse)
+	err := o.getJSON(ctx, reversePath, q, &coordinatesResponse)
 	if err != nil {
-		log.Println(err)
-		return Coordinate{}, fmt.Errorf("error unmarshal response: %w", err)
+		return weather.Coordinate{}, fmt.Errorf("error get reverse geocoding: %w", err)
 	}
 
 	if len(coordinatesResponse) == 0 {
-		return Coordinate{}, ErrCityNotFound
+		return weather.Coordinate{}, weather.ErrCityNotFound
 	}
 
-	return Coordinate{
-		Name: coordinatesResponse[0].Name,
-		Lat:  coordinatesResponse[0].Lat,
-		Lon:  coordinatesResponse[0].Lon,
+	return weather.Coordinate{
+		Name:    coordinatesResponse[0].Name,
+		Country: coordinatesResponse[0].Country,
+		State:   coordinatesResponse[0].State,
+		Lat:     coordinatesResponse[0].Lat,
+		Lon:     coordinatesResponse[0].Lon,
 	}, nil
 }
 
-func (o OpenWeatherClient) Weather(ctx context.Context, lat float64, lon float64) (Weather, error) {
-	url := fmt.Sprintf("%s?lat=%f&lon=%f&appid=%s&units=metric", o.apiURL, lat, lon, o.apiKey)
+func (o OpenWeatherClient) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	var weatherResponse WeatherResponse
+	err := o.getJSON(ctx, weatherPath, query(lat, lon, params), &weatherResponse)
+	if err != nil {
+		return weather.Weather{}, fmt.Errorf("error get weather: %w", err)
+	}
+
+	loc := time.FixedZone("", weatherResponse.Timezone)
+
+	current := weather.Weather{
+		City:       weatherResponse.Name,
+		Country:    weatherResponse.Sys.Country,
+		Time:       time.Unix(weatherResponse.Dt, 0).In(loc),
+		Temp:       weatherResponse.Main.Temp,
+		FeelsLike:  weatherResponse.Main.FeelsLike,
+		TempMin:    weatherResponse.Main.TempMin,
+		TempMax:    weatherResponse.Main.TempMax,
+		Pressure:   weatherResponse.Main.Pressure,
+		Humidity:   weatherResponse.Main.Humidity,
+		WindSpeed:  weatherResponse.Wind.Speed,
+		WindDeg:    weatherResponse.Wind.Deg,
+		WindGust:   weatherResponse.Wind.Gust,
+		Clouds:     weatherResponse.Clouds.All,
+		Visibility: weatherResponse.Visibility,
+		Rain1h:     weatherResponse.Rain.OneHour,
+		Snow1h:     weatherResponse.Snow.OneHour,
+		Sunrise:    time.Unix(weatherResponse.Sys.Sunrise, 0).In(loc),
+		Sunset:     time.Unix(weatherResponse.Sys.Sunset, 0).In(loc),
+		Timezone:   weatherResponse.Timezone,
+	}
+	if len(weatherResponse.Weather) > 0 {
+		current.ConditionID = weatherResponse.Weather[0].ID
+		current.Condition = weatherResponse.Weather[0].Main
+		current.Description = weatherResponse.Weather[0].Description
+		current.Icon = weatherResponse.Weather[0].Icon
+	}
+
+	return current, nil
+}
+
+// Forecast returns the 5 day / 3 hour forecast for the given point together
+// with per-day summaries aligned to the local midnight of that point.
+func (o OpenWeatherClient) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	var forecastResponse ForecastResponse
+	err := o.getJSON(ctx, forecastPath, query(lat, lon, params), &forecastResponse)
+	if err != nil {
+		return weather.Forecast{}, fmt.Errorf("error get forecast: %w", err)
+	}
+
+	loc := time.FixedZone("", forecastResponse.City.Timezone)
+
+	hours := make([]weather.ForecastHour, 0, len(forecastResponse.List))
+	for _, item := range forecastResponse.List {
+		hour := weather.ForecastHour{
+			Time:      time.Unix(item.Dt, 0).In(loc),
+			Temp:      item.Main.Temp,
+			TempMin:   item.Main.TempMin,
+			TempMax:   item.Main.TempMax,
+			Pop:       item.Pop,
+			WindSpeed: item.Wind.Speed,
+			WindGust:  item.Wind.Gust,
+		}
+		if len(item.Weather) > 0 {
+			hour.ConditionID = item.Weather[0].ID
+			hour.Description = item.Weather[0].Description
+		}
+		hours = append(hours, hour)
+	}
+
+	return weather.Forecast{
+		City:     forecastResponse.City.Name,
+		Timezone: forecastResponse.City.Timezone,
+		Step:     3 * time.Hour,
+		Hours:    hours,
+		Days:     dailySummaries(hours),
+	}, nil
+}
+
+// Alerts returns the official severe weather warnings in effect for the
+// point. Start and End are in the point's local time.
+func (o OpenWeatherClient) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	q := point(lat, lon)
+	q.Set("exclude", "current,minutely,hourly,daily")
+
+	var oneCallResponse OneCallResponse
+	err := o.getJSON(ctx, oneCallPath, q, &oneCallResponse)
+	if err != nil {
+		return nil, fmt.Errorf("error get alerts: %w", err)
+	}
+
+	loc := time.FixedZone("", oneCallResponse.TimezoneOffset)
+	alerts := make([]weather.Alert, 0, len(oneCallResponse.Alerts))
+	for _, a := range oneCallResponse.Alerts {
+		alerts = append(alerts, weather.Alert{
+			Sender:      a.SenderName,
+			Event:       a.Event,
+			Start:       time.Unix(a.Start, 0).In(loc),
+			End:         time.Unix(a.End, 0).In(loc),
+			Description: a.Description,
+		})
+	}
+
+	return alerts, nil
+}
+
+// Nowcast returns the minute by minute precipitation for the next hour and
+// the hourly one for the next two days. Minutely data is only available in
+// some regions, Minutes is empty elsewhere.
+func (o OpenWeatherClient) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	q := point(lat, lon)
+	q.Set("exclude", "current,daily,alerts")
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	var nowcastResponse NowcastResponse
+	err := o.getJSON(ctx, oneCallPath, q, &nowcastResponse)
 	if err != nil {
-		return Weather{}, fmt.Errorf("error creating request: %w", err)
+		return weather.Nowcast{}, fmt.Errorf("error get nowcast: %w", err)
+	}
+
+	loc := time.FixedZone("", nowcastResponse.TimezoneOffset)
+	nowcast := weather.Nowcast{
+		Timezone: nowcastResponse.TimezoneOffset,
+		Minutes:  make([]weather.Precipitation, 0, len(nowcastResponse.Minutely)),
+		Hours:    make([]weather.Precipitation, 0, len(nowcastResponse.Hourly)),
+	}
+	for _, m := range nowcastResponse.Minutely {
+		nowcast.Minutes = append(nowcast.Minutes, weather.Precipitation{
+			Time:      time.Unix(m.Dt, 0).In(loc),
+			Intensity: m.Precipitation,
+		})
+	}
+	for _, h := range nowcastResponse.Hourly {
+		nowcast.Hours = append(nowcast.Hours, weather.Precipitation{
+			Time:      time.Unix(h.Dt, 0).In(loc),
+			Intensity: h.Rain.OneHour + h.Snow.OneHour,
+			Pop:       h.Pop,
+		})
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	return nowcast, nil
+}
+
+// AirPollution returns the current air quality at the point together with
+// the hourly forecast for the next days.
+func (o OpenWeatherClient) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	var current, forecast AirPollutionResponse
+
+	err := o.getJSON(ctx, airPath, point(lat, lon), &current)
 	if err != nil {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution: %w", err)
+	}
+	if len(current.List) == 0 {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution: empty response")
+	}
+
+	err = o.getJSON(ctx, airPath+"/forecast", point(lat, lon), &forecast)
+	if err != nil {
+		return weather.AirPollution{}, fmt.Errorf("error get air pollution forecast: %w", err)
+	}
+
+	air := weather.AirPollution{
+		Current:  current.List[0].airQuality(),
+		Forecast: make([]weather.AirQuality, 0, len(forecast.List)),
+	}
+	for _, item := range forecast.List {
+		air.Forecast = append(air.Forecast, item.airQuality())
+	}
+
+	return air, nil
+}
+
+// point returns the query parameters for the coordinates.
+func point(lat float64, lon float64) url.Values {
+	return url.Values{
+		"lat": {strconv.FormatFloat(lat, 'f', 6, 64)},
+		"lon": {strconv.FormatFloat(lon, 'f', 6, 64)},
+	}
+}
+
+// query returns the query parameters for the coordinates and the user
+// preferences.
+func query(lat float64, lon float64, p weather.Params) url.Values {
+	units := p.Units
+	if units == "" {
+		units = weather.Metric
+	}
+
+	q := point(lat, lon)
+	q.Set("units", string(units))
+	if p.Lang != "" {
+		q.Set("lang", p.Lang)
+	}
+	return q
+}
+
+// getJSON decodes the response of a GET request to path with the query q
+// into v. Rate limits, 5xx statuses and network errors are retried with
+// jittered exponential backoff as long as the wait fits in the deadline of
+// ctx.
+func (o OpenWeatherClient) getJSON(ctx context.Context, path string, q url.Values, v any) error {
+	q.Set("appid", o.apiKey)
+	endpoint := o.baseURL + path + "?" + q.Encode()
+
+	for attempt := 1; ; attempt++ {
+		err := o.doJSON(ctx, endpoint, v)
+		if err == nil || attempt >= o.maxAttempts || ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, weather.ErrUpstream) && !errors.Is(err, weather.ErrRateLimited) {
+			return err
+		}
+
+		wait := backoff(o.retryBase, attempt)
+		var statusErr *weather.StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > wait {
+			wait = statusErr.RetryAfter
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// doJSON makes a single request. The API key is part of the URL, so it is
+// redacted from the errors of the request before they are logged or
+// returned.
+func (o OpenWeatherClient) doJSON(ctx context.Context, endpoint string, v any) error {
+	if o.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", redact(err))
+	}
+	if o.userAgent != "" {
+		req.Header.Set("User-Agent", o.userAgent)
+	}
+
+	resp, err := o.httpClient.Do(req)
+	if err != nil {
+		err = redact(err)
 		log.Println(err)
-		return Weather{}, fmt.Errorf("error get weather: %w", err)
+		return fmt.Errorf("error do request: %w: %w", weather.ErrUpstream, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != 200 {
-		return Weather{}, fmt.Errorf("error fail get weather: %d", resp.StatusCode)
+		return &weather.StatusError{
+			StatusCode: resp.StatusCode,
+			RetryAfter: retryAfter(resp.Header.Get("Retry-After"), time.Now()),
+		}
 	}
 
-	var weatherResponse WeatherResponse
-	err = json.NewDecoder(resp.Body).Decode(&weatherResponse)
+	err = json.NewDecoder(resp.Body).Decode(v)
 	if err != nil {
 		log.Println(err)
-		return Weather{}, fmt.Errorf("error unmarshal weather respose: %w", err)
+		return fmt.Errorf("error unmarshal response: %w", err)
 	}
 
-	return Weather{
-		Temp: weatherResponse.Main.Temp,
-	}, nil
+	return nil
+}
+
+// redact hides the API key in the URL of a request error.
+func redact(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = redactURL(urlErr.URL)
+	}
+	return err
+}
+
+func redactURL(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	q := u.Query()
+	if q.Has("appid") {
+		q.Set("appid", "REDACTED")
+		u.RawQuery = q.Encode()
+	}
+	return u.String()
+}
+
+// backoff returns the wait before the retry following attempt: the doubled
+// base with the upper half jittered so clients don't retry in lockstep.
+func backoff(base time.Duration, attempt int) time.Duration {
+	d := base << (attempt - 1)
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses the Retry-After header, given either in seconds or as a
+// date.
+func retryAfter(header string, now time.Time) time.Duration {
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(header); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
 }
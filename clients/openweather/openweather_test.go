@@ -2,9 +2,13 @@ package openweather
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"study/weatherbot/weather"
 	"testing"
+	"time"
 )
 
 func TestOpenWeatherClient_Coordinates(t *testing.T) {
@@ -13,7 +17,7 @@ func TestOpenWeatherClient_Coordinates(t *testing.T) {
 		q := r.URL.Query().Get("q")
 		if q == "Moscow" {
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`[{"name": "Moscow", "lat": 55.7558, "lon": 37.6173}]`))
+			w.Write([]byte(`[{"name": "Moscow", "lat": 55.7558, "lon": 37.6173, "country": "RU", "state": "Moscow"}]`))
 		} else if q == "NotFound" {
 			w.WriteHeader(http.StatusOK)
 			w.Write([]byte(`[]`))
@@ -25,8 +29,7 @@ func TestOpenWeatherClient_Coordinates(t *testing.T) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	client := New("dummy_key")
-	client.geoURL = server.URL + "/geo/1.0/direct"
+	client := New("dummy_key", WithBaseURL(server.URL))
 
 	tests := []struct {
 		name     string
@@ -54,6 +57,81 @@ func TestOpenWeatherClient_Coordinates(t *testing.T) {
 				if coord.Name != tt.wantName {
 					t.Errorf("got Name %v, want %v", coord.Name, tt.wantName)
 				}
+				if coord.Country != "RU" || coord.State != "Moscow" {
+					t.Errorf("got Country %v, State %v, want RU, Moscow", coord.Country, coord.State)
+				}
+			}
+		})
+	}
+}
+
+func TestOpenWeatherClient_Cities(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/geo/1.0/direct", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`[
+			{"name": "Springfield", "lat": 39.799, "lon": -89.644, "country": "US", "state": "Illinois"},
+			{"name": "Springfield", "lat": 39.781, "lon": -89.650, "country": "US", "state": "Illinois"},
+			{"name": "Springfield", "lat": 37.215, "lon": -93.298, "country": "US", "state": "Missouri"}
+		]`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	cities, err := client.Cities(context.Background(), "Springfield")
+	if err != nil {
+		t.Fatalf("Cities() error = %v", err)
+	}
+	if len(cities) != 2 {
+		t.Fatalf("got %d cities, want duplicates to be dropped: %+v", len(cities), cities)
+	}
+	if cities[0].State != "Illinois" || cities[0].Lat != 39.799 || cities[1].State != "Missouri" {
+		t.Errorf("unexpected cities: %+v", cities)
+	}
+}
+
+func TestOpenWeatherClient_ReverseGeocode(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/geo/1.0/reverse", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("limit") != "1" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("lat") == "55.755800" {
+			w.Write([]byte(`[{"name": "Moscow", "lat": 55.7504, "lon": 37.6175, "country": "RU", "state": "Moscow"}]`))
+		} else {
+			w.Write([]byte(`[]`))
+		}
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	tests := []struct {
+		name     string
+		lat      float64
+		lon      float64
+		wantErr  error
+		wantName string
+	}{
+		{"Known place", 55.7558, 37.6173, nil, "Moscow"},
+		{"Open sea", 0, 0, weather.ErrCityNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			coord, err := client.ReverseGeocode(context.Background(), tt.lat, tt.lon)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReverseGeocode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if coord.Name != tt.wantName {
+				t.Errorf("got Name %v, want %v", coord.Name, tt.wantName)
 			}
 		})
 	}
@@ -63,9 +141,20 @@ func TestOpenWeatherClient_Weather(t *testing.T) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/data/2.5/weather", func(w http.ResponseWriter, r *http.Request) {
 		lat := r.URL.Query().Get("lat")
-		if lat == "55.755800" {
+		if lat == "55.755800" && r.URL.Query().Get("lang") == "ru" && r.URL.Query().Get("units") == "metric" {
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(`{"main": {"temp": 12.5}}`))
+			w.Write([]byte(`{
+				"name": "Moscow",
+				"dt": 1765821600,
+				"weather": [{"id": 500, "main": "Rain", "description": "небольшой дождь", "icon": "10n"}],
+				"main": {"temp": 12.5, "feels_like": 11.2, "temp_min": 11, "temp_max": 13.4, "pressure": 1009, "humidity": 82},
+				"visibility": 8000,
+				"wind": {"speed": 5.1, "deg": 200, "gust": 11.3},
+				"clouds": {"all": 90},
+				"rain": {"1h": 0.7},
+				"sys": {"country": "RU", "sunrise": 1765778100, "sunset": 1765803600},
+				"timezone": 10800
+			}`))
 		} else {
 			w.WriteHeader(http.StatusNotFound)
 		}
@@ -74,30 +163,422 @@ func TestOpenWeatherClient_Weather(t *testing.T) {
 	server := httptest.NewServer(mux)
 	defer server.Close()
 
-	client := New("dummy_key")
-	client.apiURL = server.URL + "/data/2.5/weather"
+	client := New("dummy_key", WithBaseURL(server.URL))
 
 	tests := []struct {
 		name    string
 		lat     float64
 		lon     float64
 		wantErr bool
-		wantTmp float64
+		want    weather.Weather
 	}{
-		{"Valid coords", 55.7558, 37.6173, false, 12.5},
-		{"Invalid coords", 0, 0, true, 0},
+		{"Valid coords", 55.7558, 37.6173, false, weather.Weather{
+			City:        "Moscow",
+			Country:     "RU",
+			Temp:        12.5,
+			FeelsLike:   11.2,
+			TempMin:     11,
+			TempMax:     13.4,
+			Pressure:    1009,
+			Humidity:    82,
+			WindSpeed:   5.1,
+			WindDeg:     200,
+			WindGust:    11.3,
+			Clouds:      90,
+			Visibility:  8000,
+			Rain1h:      0.7,
+			ConditionID: 500,
+			Condition:   "Rain",
+			Description: "небольшой дождь",
+			Icon:        "10n",
+			Timezone:    10800,
+		}},
+		{"Invalid coords", 0, 0, true, weather.Weather{}},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			weather, err := client.Weather(context.Background(), tt.lat, tt.lon)
+			got, err := client.Weather(context.Background(), tt.lat, tt.lon, weather.Params{Lang: "ru"})
 			if (err != nil) != tt.wantErr {
 				t.Errorf("Weather() error = %v, wantErr %v", err, tt.wantErr)
 				return
 			}
-			if !tt.wantErr && weather.Temp != tt.wantTmp {
-				t.Errorf("got Temp %v, want %v", weather.Temp, tt.wantTmp)
+			if tt.wantErr {
+				return
+			}
+			if sunrise := got.Sunrise.Format("15:04"); sunrise != "08:55" {
+				t.Errorf("got local Sunrise %v, want 08:55", sunrise)
+			}
+			if sunset := got.Sunset.Format("15:04"); sunset != "16:00" {
+				t.Errorf("got local Sunset %v, want 16:00", sunset)
+			}
+			got.Time, got.Sunrise, got.Sunset = time.Time{}, time.Time{}, time.Time{}
+			if got != tt.want {
+				t.Errorf("got Weather %+v, want %+v", got, tt.want)
 			}
 		})
 	}
 }
+
+func TestOpenWeatherClient_Forecast(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/2.5/forecast", func(w http.ResponseWriter, r *http.Request) {
+		lat := r.URL.Query().Get("lat")
+		if lat != "55.755800" || r.URL.Query().Get("units") != "imperial" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		// Slots are 2025-12-15 18:00, 21:00 and 2025-12-16 00:00 UTC, which is
+		// 21:00 on the 15th and 00:00, 03:00 on the 16th in UTC+3.
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{
+			"list": [
+				{"dt": 1765821600, "main": {"temp": -1, "temp_min": -2, "temp_max": 0}, "weather": [{"id": 600, "description": "снег"}], "pop": 0.8},
+				{"dt": 1765832400, "main": {"temp": -4, "temp_min": -5, "temp_max": -3}, "weather": [{"id": 804, "description": "пасмурно"}], "pop": 0.1},
+				{"dt": 1765843200, "main": {"temp": -6, "temp_min": -7, "temp_max": -4}, "weather": [{"id": 804, "description": "пасмурно"}], "pop": 0.3}
+			],
+			"city": {"name": "Moscow", "timezone": 10800}
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	t.Run("Invalid coords", func(t *testing.T) {
+		_, err := client.Forecast(context.Background(), 0, 0, weather.Params{})
+		if err == nil {
+			t.Errorf("Forecast() error = nil, want error")
+		}
+	})
+
+	t.Run("Days follow local midnight", func(t *testing.T) {
+		forecast, err := client.Forecast(context.Background(), 55.7558, 37.6173, weather.Params{Lang: "ru", Units: weather.Imperial})
+		if err != nil {
+			t.Fatalf("Forecast() error = %v", err)
+		}
+		if len(forecast.Hours) != 3 {
+			t.Fatalf("got %d hours, want 3", len(forecast.Hours))
+		}
+
+		wantDays := []struct {
+			day         int
+			min, max    float64
+			pop         float64
+			description string
+		}{
+			{15, -2, 0, 0.8, "снег"},
+			{16, -7, -3, 0.3, "пасмурно"},
+		}
+		if len(forecast.Days) != len(wantDays) {
+			t.Fatalf("got %d days, want %d", len(forecast.Days), len(wantDays))
+		}
+		for i, want := range wantDays {
+			got := forecast.Days[i]
+			if got.Date.Day() != want.day || got.Date.Hour() != 0 {
+				t.Errorf("day %d: got date %v, want local midnight of the %dth", i, got.Date, want.day)
+			}
+			if got.TempMin != want.min || got.TempMax != want.max {
+				t.Errorf("day %d: got %v…%v, want %v…%v", i, got.TempMin, got.TempMax, want.min, want.max)
+			}
+			if got.Pop != want.pop {
+				t.Errorf("day %d: got Pop %v, want %v", i, got.Pop, want.pop)
+			}
+			if got.Description != want.description {
+				t.Errorf("day %d: got Description %v, want %v", i, got.Description, want.description)
+			}
+		}
+		if _, offset := forecast.Days[0].Date.Zone(); offset != 10800 {
+			t.Errorf("got zone offset %d, want 10800", offset)
+		}
+	})
+}
+
+func TestOpenWeatherClient_Alerts(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/3.0/onecall", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("exclude") != "current,minutely,hourly,daily" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Query().Get("lat") != "55.755800" {
+			w.Write([]byte(`{"timezone_offset": 10800}`))
+			return
+		}
+		w.Write([]byte(`{
+			"timezone_offset": 10800,
+			"alerts": [{
+				"sender_name": "Гидрометцентр России",
+				"event": "Сильный ветер",
+				"start": 1765792800,
+				"end": 1765836000,
+				"description": "Порывы ветра 15-20 м/с",
+				"tags": ["Wind"]
+			}]
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	alerts, err := client.Alerts(context.Background(), 55.7558, 37.6173)
+	if err != nil {
+		t.Fatalf("Alerts() error = %v", err)
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	got := alerts[0]
+	if got.Sender != "Гидрометцентр России" || got.Event != "Сильный ветер" || got.Description != "Порывы ветра 15-20 м/с" {
+		t.Errorf("unexpected alert %+v", got)
+	}
+	if got.Start.Format("02.01 15:04") != "15.12 13:00" || got.End.Format("02.01 15:04") != "16.12 01:00" {
+		t.Errorf("got alert period %v - %v, want local time", got.Start, got.End)
+	}
+
+	alerts, err = client.Alerts(context.Background(), 59.9386, 30.3141)
+	if err != nil || len(alerts) != 0 {
+		t.Errorf("got %v, %v, want no alerts", alerts, err)
+	}
+}
+
+func TestOpenWeatherClient_Nowcast(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/3.0/onecall", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("exclude") != "current,daily,alerts" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{
+			"timezone_offset": 10800,
+			"minutely": [
+				{"dt": 1765792800, "precipitation": 0},
+				{"dt": 1765792860, "precipitation": 1.2}
+			],
+			"hourly": [
+				{"dt": 1765792800, "pop": 0.4, "rain": {"1h": 0.5}},
+				{"dt": 1765796400, "pop": 0.8, "rain": {"1h": 1.5}, "snow": {"1h": 0.3}}
+			]
+		}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	nowcast, err := client.Nowcast(context.Background(), 55.7558, 37.6173)
+	if err != nil {
+		t.Fatalf("Nowcast() error = %v", err)
+	}
+	if len(nowcast.Minutes) != 2 || nowcast.Minutes[1].Intensity != 1.2 || nowcast.Minutes[1].Time.Format("15:04") != "13:01" {
+		t.Errorf("unexpected minutely precipitation %+v", nowcast.Minutes)
+	}
+	if len(nowcast.Hours) != 2 || nowcast.Hours[1].Intensity != 1.8 || nowcast.Hours[1].Pop != 0.8 {
+		t.Errorf("unexpected hourly precipitation %+v", nowcast.Hours)
+	}
+}
+
+func TestOpenWeatherClient_AirPollution(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/2.5/air_pollution", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("lat") != "55.755800" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"list": [{
+			"dt": 1765792800,
+			"main": {"aqi": 2},
+			"components": {"co": 270.37, "no": 0.1, "no2": 14.9, "o3": 55.1, "so2": 4.2, "pm2_5": 12.4, "pm10": 18.7, "nh3": 1.3}
+		}]}`))
+	})
+	mux.HandleFunc("/data/2.5/air_pollution/forecast", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"list": [
+			{"dt": 1765796400, "main": {"aqi": 3}, "components": {"pm2_5": 30.2}},
+			{"dt": 1765800000, "main": {"aqi": 1}, "components": {"pm2_5": 5.5}}
+		]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	air, err := client.AirPollution(context.Background(), 55.7558, 37.6173)
+	if err != nil {
+		t.Fatalf("AirPollution() error = %v", err)
+	}
+	want := weather.AirQuality{
+		Time: time.Unix(1765792800, 0).UTC(),
+		AQI:  2,
+		CO:   270.37, NO: 0.1, NO2: 14.9, O3: 55.1, SO2: 4.2, PM25: 12.4, PM10: 18.7, NH3: 1.3,
+	}
+	if air.Current != want {
+		t.Errorf("got current %+v, want %+v", air.Current, want)
+	}
+	if len(air.Forecast) != 2 || air.Forecast[0].AQI != 3 || air.Forecast[0].PM25 != 30.2 {
+		t.Errorf("unexpected forecast %+v", air.Forecast)
+	}
+
+	_, err = client.AirPollution(context.Background(), 0, 0)
+	if err == nil {
+		t.Errorf("AirPollution() error = nil, want error")
+	}
+}
+
+func TestOpenWeatherClient_Retry(t *testing.T) {
+	var calls int
+	var statuses []int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/2.5/weather", func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[min(calls, len(statuses)-1)]
+		calls++
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "120")
+		}
+		w.WriteHeader(status)
+		w.Write([]byte(`{"name": "Moscow"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL), WithRetry(3, time.Millisecond))
+
+	tests := []struct {
+		name      string
+		statuses  []int
+		wantCalls int
+		wantErr   error
+	}{
+		{"Transient 502", []int{http.StatusBadGateway, http.StatusOK}, 2, nil},
+		{"Upstream down", []int{http.StatusServiceUnavailable}, 3, weather.ErrUpstream},
+		{"Invalid key", []int{http.StatusUnauthorized}, 1, weather.ErrUnauthorized},
+		{"Not found", []int{http.StatusNotFound}, 1, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls, statuses = 0, tt.statuses
+			_, err := client.Weather(context.Background(), 55.7558, 37.6173, weather.Params{})
+			if calls != tt.wantCalls {
+				t.Errorf("got %d requests, want %d", calls, tt.wantCalls)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("Retry-After beyond the deadline", func(t *testing.T) {
+		calls, statuses = 0, []int{http.StatusTooManyRequests, http.StatusOK}
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		_, err := client.Weather(ctx, 55.7558, 37.6173, weather.Params{})
+		var statusErr *weather.StatusError
+		if !errors.Is(err, weather.ErrRateLimited) || !errors.As(err, &statusErr) || statusErr.RetryAfter != 2*time.Minute {
+			t.Errorf("got error %v, want rate limited for 2m", err)
+		}
+		if calls != 1 {
+			t.Errorf("got %d requests, want no retry that can't finish in time", calls)
+		}
+	})
+}
+
+type countingTransport struct {
+	requests int
+}
+
+func (c *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	c.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestOpenWeatherClient_Options(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/data/2.5/weather", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("User-Agent") != "weatherbot-test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.URL.Query().Get("lat") == "0.000000" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(`{"name": "Moscow"}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	transport := &countingTransport{}
+	client := New("dummy_key",
+		WithBaseURL(server.URL+"/"),
+		WithHTTPClient(&http.Client{Transport: transport}),
+		WithUserAgent("weatherbot-test"),
+		WithTimeout(20*time.Millisecond),
+		WithRetry(1, 0),
+	)
+
+	got, err := client.Weather(context.Background(), 55.7558, 37.6173, weather.Params{})
+	if err != nil || got.City != "Moscow" {
+		t.Fatalf("got %q, %v, want Moscow", got.City, err)
+	}
+	if transport.requests != 1 {
+		t.Errorf("got %d requests through the custom client, want 1", transport.requests)
+	}
+
+	_, err = client.Weather(context.Background(), 0, 0, weather.Params{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the request timeout", err)
+	}
+}
+
+func TestOpenWeatherClient_QueryEscaping(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/geo/1.0/direct", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("q") != "Saint-Denis, Réunion & limit=1" || q.Get("limit") != "5" || len(q["appid"]) != 1 {
+			w.Write([]byte(`[]`))
+			return
+		}
+		w.Write([]byte(`[{"name": "Saint-Denis", "lat": -20.8823, "lon": 55.4504, "country": "RE"}]`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := New("dummy_key", WithBaseURL(server.URL))
+
+	coord, err := client.Coordinates(context.Background(), "Saint-Denis, Réunion & limit=1")
+	if err != nil || coord.Name != "Saint-Denis" {
+		t.Errorf("got %q, %v, want the city name passed as a single parameter", coord.Name, err)
+	}
+}
+
+func TestOpenWeatherClient_RedactsAPIKey(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	client := New("secret_key", WithBaseURL(server.URL), WithRetry(1, 0))
+
+	_, err := client.Weather(context.Background(), 55.7558, 37.6173, weather.Params{})
+	if err == nil {
+		t.Fatal("got no error from a closed server")
+	}
+	if strings.Contains(err.Error(), "secret_key") {
+		t.Errorf("got the API key in error %q", err)
+	}
+	if !strings.Contains(err.Error(), "appid=REDACTED") {
+		t.Errorf("got error %q, want the redacted URL", err)
+	}
+	if !errors.Is(err, weather.ErrUpstream) {
+		t.Errorf("got error %v, want ErrUpstream kept", err)
+	}
+}
@@ -2,15 +2,83 @@ package config
 
 import (
 	"fmt"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
+const (
+	UpdateModePolling = "polling"
+	UpdateModeWebhook = "webhook"
+
+	WeatherProviderOpenWeather          = "openweather"
+	WeatherProviderOpenWeatherSecondary = "openweather-secondary"
+	WeatherProviderOpenMeteo            = "openmeteo"
+)
+
 type Config struct {
 	BotToken          string
 	OpenWeatherAPIKey string
-	DatabaseURL       string
+	// OpenWeatherSecondaryAPIKey is the key of the second OpenWeather
+	// account, used by the openweather-secondary provider.
+	OpenWeatherSecondaryAPIKey string
+
+	// OpenWeatherBaseURL replaces the OpenWeather API host, e.g. with a mock
+	// in staging. Requests go through OpenWeatherProxyURL when it is set,
+	// each one bounded by OpenWeatherTimeout.
+	OpenWeatherBaseURL   string
+	OpenWeatherProxyURL  string
+	OpenWeatherUserAgent string
+	OpenWeatherTimeout   time.Duration
+	// OpenMeteoBaseURL replaces the Open-Meteo geocoding, forecast and air
+	// quality hosts with one, e.g. a mock in staging.
+	OpenMeteoBaseURL string
+	DatabaseURL      string
+
+	// WeatherProviders are the names of the weather backends in the
+	// registry, in the order requests fail over to them. The API keys are
+	// only needed for the providers listed.
+	WeatherProviders []string
+
+	// FailoverThreshold consecutive 5xx, 429 or timeouts open the circuit
+	// of a provider for FailoverCooldown. FailoverTimeout bounds a request
+	// to one provider.
+	FailoverThreshold int
+	FailoverCooldown  time.Duration
+	FailoverTimeout   time.Duration
+
+	// UpdateMode selects how updates are received from Telegram: long
+	// polling or a webhook served on WebhookListenAddr.
+	UpdateMode        string
+	WebhookURL        string // public base URL Telegram delivers updates to
+	WebhookPath       string
+	WebhookListenAddr string
+	WebhookSecret     string // checked against X-Telegram-Bot-Api-Secret-Token
+
+	CoordinatesCacheTTL time.Duration
+	WeatherCacheTTL     time.Duration
+
+	// AlertCheckInterval is how often alert rules are evaluated, zero
+	// disables alerts.
+	AlertCheckInterval time.Duration
+
+	// MetricsAddr is where expvar metrics are served, empty disables them.
+	MetricsAddr string
+
+	// UserRateLimit is how many commands per minute a user may send on
+	// average, UserRateBurst of them in a row, zero for no limit.
+	// MaxConcurrentUpdates bounds the updates handled at the same time.
+	UserRateLimit        int
+	UserRateBurst        int
+	MaxConcurrentUpdates int
+
+	// AdminIDs are the Telegram user IDs allowed to run admin commands.
+	AdminIDs []int64
 }
 
 func Load() (*Config, error) {
@@ -18,20 +86,184 @@ func Load() (*Config, error) {
 	_ = godotenv.Load()
 
 	cfg := &Config{
-		BotToken:          os.Getenv("BOT_TOKEN"),
-		OpenWeatherAPIKey: os.Getenv("OPEN_WEATHER_API_KEY"),
-		DatabaseURL:       os.Getenv("DATABASE_URL"),
+		BotToken:                   os.Getenv("BOT_TOKEN"),
+		OpenWeatherAPIKey:          os.Getenv("OPEN_WEATHER_API_KEY"),
+		OpenWeatherSecondaryAPIKey: os.Getenv("OPEN_WEATHER_SECONDARY_API_KEY"),
+		DatabaseURL:                os.Getenv("DATABASE_URL"),
+		WeatherProviders:           getList("WEATHER_PROVIDER", WeatherProviderOpenWeather),
+		OpenWeatherBaseURL:         os.Getenv("OPEN_WEATHER_BASE_URL"),
+		OpenWeatherProxyURL:        os.Getenv("OPEN_WEATHER_PROXY_URL"),
+		OpenWeatherUserAgent:       getEnv("OPEN_WEATHER_USER_AGENT", "go-weather-bot"),
+		OpenMeteoBaseURL:           os.Getenv("OPEN_METEO_BASE_URL"),
+		UpdateMode:                 getEnv("UPDATE_MODE", UpdateModePolling),
+		WebhookURL:                 os.Getenv("WEBHOOK_URL"),
+		WebhookPath:                getEnv("WEBHOOK_PATH", "/telegram/webhook"),
+		WebhookListenAddr:          getEnv("WEBHOOK_LISTEN_ADDR", ":8080"),
+		WebhookSecret:              os.Getenv("WEBHOOK_SECRET"),
+		MetricsAddr:                os.Getenv("METRICS_ADDR"),
+	}
+
+	var err error
+	cfg.CoordinatesCacheTTL, err = getDuration("COORDINATES_CACHE_TTL", 24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+	// OpenWeather updates current weather about every 10 minutes.
+	cfg.WeatherCacheTTL, err = getDuration("WEATHER_CACHE_TTL", 10*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.AlertCheckInterval, err = getDuration("ALERT_CHECK_INTERVAL", 30*time.Minute)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.OpenWeatherTimeout, err = getDuration("OPEN_WEATHER_TIMEOUT", 5*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.FailoverThreshold, err = getPositiveInt("FAILOVER_THRESHOLD", 5)
+	if err != nil {
+		return nil, err
+	}
+	cfg.FailoverCooldown, err = getDuration("FAILOVER_COOLDOWN", 30*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	cfg.FailoverTimeout, err = getDuration("FAILOVER_TIMEOUT", 4*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	// Zero turns the per-user limit off.
+	cfg.UserRateLimit, err = getNonNegativeInt("USER_RATE_LIMIT", 20)
+	if err != nil {
+		return nil, err
+	}
+	cfg.UserRateBurst, err = getPositiveInt("USER_RATE_BURST", 5)
+	if err != nil {
+		return nil, err
+	}
+	cfg.MaxConcurrentUpdates, err = getPositiveInt("MAX_CONCURRENT_UPDATES", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range getList("ADMIN_IDS", "") {
+		id, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ADMIN_IDS must be comma-separated user IDs, got %q", item)
+		}
+		cfg.AdminIDs = append(cfg.AdminIDs, id)
 	}
 
 	if cfg.BotToken == "" {
 		return nil, fmt.Errorf("BOT_TOKEN is required")
 	}
-	if cfg.OpenWeatherAPIKey == "" {
+	if len(cfg.WeatherProviders) == 0 {
+		return nil, fmt.Errorf("WEATHER_PROVIDER must name at least one provider")
+	}
+	for i, name := range cfg.WeatherProviders {
+		if slices.Contains(cfg.WeatherProviders[:i], name) {
+			return nil, fmt.Errorf("WEATHER_PROVIDER lists %q twice", name)
+		}
+	}
+	if slices.Contains(cfg.WeatherProviders, WeatherProviderOpenWeather) && cfg.OpenWeatherAPIKey == "" {
 		return nil, fmt.Errorf("OPEN_WEATHER_API_KEY is required")
 	}
+	if slices.Contains(cfg.WeatherProviders, WeatherProviderOpenWeatherSecondary) && cfg.OpenWeatherSecondaryAPIKey == "" {
+		return nil, fmt.Errorf("OPEN_WEATHER_SECONDARY_API_KEY is required for %s", WeatherProviderOpenWeatherSecondary)
+	}
+	for key, value := range map[string]string{
+		"OPEN_WEATHER_BASE_URL":  cfg.OpenWeatherBaseURL,
+		"OPEN_WEATHER_PROXY_URL": cfg.OpenWeatherProxyURL,
+		"OPEN_METEO_BASE_URL":    cfg.OpenMeteoBaseURL,
+	} {
+		if value == "" {
+			continue
+		}
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return nil, fmt.Errorf("%s must be an absolute URL, got %q", key, value)
+		}
+	}
 	if cfg.DatabaseURL == "" {
 		return nil, fmt.Errorf("DATABASE_URL is required")
 	}
 
+	switch cfg.UpdateMode {
+	case UpdateModePolling:
+	case UpdateModeWebhook:
+		if cfg.WebhookURL == "" {
+			return nil, fmt.Errorf("WEBHOOK_URL is required in webhook mode")
+		}
+		if cfg.WebhookSecret == "" {
+			return nil, fmt.Errorf("WEBHOOK_SECRET is required in webhook mode")
+		}
+		if !strings.HasPrefix(cfg.WebhookPath, "/") {
+			return nil, fmt.Errorf("WEBHOOK_PATH must start with /")
+		}
+	default:
+		return nil, fmt.Errorf("UPDATE_MODE must be %q or %q, got %q", UpdateModePolling, UpdateModeWebhook, cfg.UpdateMode)
+	}
+
 	return cfg, nil
 }
+
+func getDuration(key string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative duration, got %q", key, value)
+	}
+	return d, nil
+}
+
+func getPositiveInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("%s must be a positive integer, got %q", key, value)
+	}
+	return n, nil
+}
+
+func getNonNegativeInt(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer, got %q", key, value)
+	}
+	return n, nil
+}
+
+// getList splits a comma-separated value, blank items are dropped.
+func getList(key string, fallback string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, fallback), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func getEnv(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -2,18 +2,32 @@ package config
 
 import (
 	"os"
+	"slices"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
+	keys := []string{
+		"BOT_TOKEN", "OPEN_WEATHER_API_KEY", "DATABASE_URL",
+		"UPDATE_MODE", "WEBHOOK_URL", "WEBHOOK_PATH", "WEBHOOK_LISTEN_ADDR", "WEBHOOK_SECRET",
+		"COORDINATES_CACHE_TTL", "WEATHER_CACHE_TTL", "METRICS_ADDR",
+		"ALERT_CHECK_INTERVAL", "WEATHER_PROVIDER", "OPEN_WEATHER_SECONDARY_API_KEY",
+		"FAILOVER_THRESHOLD", "FAILOVER_COOLDOWN", "FAILOVER_TIMEOUT",
+		"OPEN_WEATHER_BASE_URL", "OPEN_WEATHER_PROXY_URL", "OPEN_WEATHER_USER_AGENT", "OPEN_WEATHER_TIMEOUT",
+		"OPEN_METEO_BASE_URL",
+		"USER_RATE_LIMIT", "USER_RATE_BURST", "MAX_CONCURRENT_UPDATES", "ADMIN_IDS",
+	}
+
 	// Save original env vars and restore after test
-	origBotToken := os.Getenv("BOT_TOKEN")
-	origWeatherKey := os.Getenv("OPEN_WEATHER_API_KEY")
-	origDBURL := os.Getenv("DATABASE_URL")
+	orig := make(map[string]string)
+	for _, k := range keys {
+		orig[k] = os.Getenv(k)
+	}
 	defer func() {
-		os.Setenv("BOT_TOKEN", origBotToken)
-		os.Setenv("OPEN_WEATHER_API_KEY", origWeatherKey)
-		os.Setenv("DATABASE_URL", origDBURL)
+		for k, v := range orig {
+			os.Setenv(k, v)
+		}
 	}()
 
 	tests := []struct {
@@ -46,6 +60,179 @@ func TestLoad(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Open-Meteo without API key",
+			envs: map[string]string{
+				"BOT_TOKEN":        "test_token",
+				"DATABASE_URL":     "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER": "openmeteo",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Secondary OpenWeather without its key",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER":     "openweather, openweather-secondary",
+			},
+			wantErr: true,
+		},
+		{
+			name: "No weather providers",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER":     " , ",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Repeated weather provider",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER":     "openweather, openmeteo, openweather",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid failover threshold",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"FAILOVER_THRESHOLD":   "0",
+			},
+			wantErr: true,
+		},
+		{
+			name: "OpenWeather through a proxy",
+			envs: map[string]string{
+				"BOT_TOKEN":              "test_token",
+				"OPEN_WEATHER_API_KEY":   "test_key",
+				"DATABASE_URL":           "postgres://localhost:5432/test",
+				"OPEN_WEATHER_BASE_URL":  "http://weather-mock:8081",
+				"OPEN_WEATHER_PROXY_URL": "http://egress.corp:3128",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Relative proxy URL",
+			envs: map[string]string{
+				"BOT_TOKEN":              "test_token",
+				"OPEN_WEATHER_API_KEY":   "test_key",
+				"DATABASE_URL":           "postgres://localhost:5432/test",
+				"OPEN_WEATHER_PROXY_URL": "egress.corp:3128",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Open-Meteo mock",
+			envs: map[string]string{
+				"BOT_TOKEN":           "test_token",
+				"DATABASE_URL":        "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER":    "openmeteo",
+				"OPEN_METEO_BASE_URL": "http://weather-mock:8082",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Relative Open-Meteo URL",
+			envs: map[string]string{
+				"BOT_TOKEN":           "test_token",
+				"DATABASE_URL":        "postgres://localhost:5432/test",
+				"WEATHER_PROVIDER":    "openmeteo",
+				"OPEN_METEO_BASE_URL": "weather-mock:8082",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Webhook mode",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"UPDATE_MODE":          "webhook",
+				"WEBHOOK_URL":          "https://bot.example.com",
+				"WEBHOOK_SECRET":       "s3cret",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Webhook mode without secret",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"UPDATE_MODE":          "webhook",
+				"WEBHOOK_URL":          "https://bot.example.com",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Unknown update mode",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"UPDATE_MODE":          "carrier-pigeon",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Invalid cache TTL",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"WEATHER_CACHE_TTL":    "ten minutes",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Rate limit off",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"USER_RATE_LIMIT":      "0",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Negative rate limit",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"USER_RATE_LIMIT":      "-1",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Admins",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"ADMIN_IDS":            "12345, 67890",
+			},
+			wantErr: false,
+		},
+		{
+			name: "Invalid admin ID",
+			envs: map[string]string{
+				"BOT_TOKEN":            "test_token",
+				"OPEN_WEATHER_API_KEY": "test_key",
+				"DATABASE_URL":         "postgres://localhost:5432/test",
+				"ADMIN_IDS":            "@admin",
+			},
+			wantErr: true,
+		},
 		{
 			name: "Missing DB URL",
 			envs: map[string]string{
@@ -59,9 +246,9 @@ func TestLoad(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			// Clear relevant env vars
-			os.Unsetenv("BOT_TOKEN")
-			os.Unsetenv("OPEN_WEATHER_API_KEY")
-			os.Unsetenv("DATABASE_URL")
+			for _, k := range keys {
+				os.Unsetenv(k)
+			}
 
 			// Set test env vars
 			for k, v := range tt.envs {
@@ -78,6 +265,39 @@ func TestLoad(t *testing.T) {
 				if cfg.BotToken != tt.envs["BOT_TOKEN"] {
 					t.Errorf("got BotToken %v, want %v", cfg.BotToken, tt.envs["BOT_TOKEN"])
 				}
+				if tt.envs["UPDATE_MODE"] == "" && cfg.UpdateMode != UpdateModePolling {
+					t.Errorf("got UpdateMode %v, want %v by default", cfg.UpdateMode, UpdateModePolling)
+				}
+				if cfg.WebhookPath != "/telegram/webhook" || cfg.WebhookListenAddr != ":8080" {
+					t.Errorf("got webhook defaults %v %v", cfg.WebhookPath, cfg.WebhookListenAddr)
+				}
+				if cfg.CoordinatesCacheTTL != 24*time.Hour || cfg.WeatherCacheTTL != 10*time.Minute {
+					t.Errorf("got cache TTL defaults %v %v", cfg.CoordinatesCacheTTL, cfg.WeatherCacheTTL)
+				}
+				if tt.envs["WEATHER_PROVIDER"] == "" && !slices.Equal(cfg.WeatherProviders, []string{WeatherProviderOpenWeather}) {
+					t.Errorf("got WeatherProviders %v, want %v by default", cfg.WeatherProviders, WeatherProviderOpenWeather)
+				}
+				if cfg.OpenWeatherTimeout != 5*time.Second || cfg.OpenWeatherUserAgent != "go-weather-bot" {
+					t.Errorf("got OpenWeather defaults %v %q", cfg.OpenWeatherTimeout, cfg.OpenWeatherUserAgent)
+				}
+				if cfg.FailoverThreshold != 5 || cfg.FailoverCooldown != 30*time.Second || cfg.FailoverTimeout != 4*time.Second {
+					t.Errorf("got failover defaults %v %v %v", cfg.FailoverThreshold, cfg.FailoverCooldown, cfg.FailoverTimeout)
+				}
+				if tt.envs["USER_RATE_LIMIT"] == "0" && cfg.UserRateLimit != 0 {
+					t.Errorf("got UserRateLimit %v, want 0 to turn the limit off", cfg.UserRateLimit)
+				}
+				if tt.envs["USER_RATE_LIMIT"] == "" && cfg.UserRateLimit != 20 {
+					t.Errorf("got UserRateLimit %v, want 20 by default", cfg.UserRateLimit)
+				}
+				if cfg.UserRateBurst != 5 || cfg.MaxConcurrentUpdates != 100 {
+					t.Errorf("got rate limit defaults %v %v", cfg.UserRateBurst, cfg.MaxConcurrentUpdates)
+				}
+				if tt.envs["ADMIN_IDS"] != "" && !slices.Equal(cfg.AdminIDs, []int64{12345, 67890}) {
+					t.Errorf("got AdminIDs %v", cfg.AdminIDs)
+				}
+				if cfg.AlertCheckInterval != 30*time.Minute {
+					t.Errorf("got AlertCheckInterval %v, want 30m by default", cfg.AlertCheckInterval)
+				}
 			}
 		})
 	}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const airForecastWindow = 24 * time.Hour
+
+// pollutant is a pollutant shown by /air with the upper bounds of the good,
+// fair, moderate and poor levels of the OpenWeather air quality index in
+// μg/m³, higher concentrations are very poor.
+type pollutant struct {
+	name   string
+	value  func(weather.AirQuality) float64
+	limits [4]float64
+}
+
+var pollutants = []pollutant{
+	{"PM2.5", func(q weather.AirQuality) float64 { return q.PM25 }, [4]float64{10, 25, 50, 75}},
+	{"PM10", func(q weather.AirQuality) float64 { return q.PM10 }, [4]float64{20, 50, 100, 200}},
+	{"O3", func(q weather.AirQuality) float64 { return q.O3 }, [4]float64{60, 100, 140, 180}},
+	{"NO2", func(q weather.AirQuality) float64 { return q.NO2 }, [4]float64{40, 70, 150, 200}},
+	{"SO2", func(q weather.AirQuality) float64 { return q.SO2 }, [4]float64{20, 80, 250, 350}},
+	{"CO", func(q weather.AirQuality) float64 { return q.CO }, [4]float64{4400, 9400, 12400, 15400}},
+}
+
+// level returns the air quality index the concentration corresponds to.
+func (p pollutant) level(v float64) int {
+	for i, limit := range p.limits {
+		if v < limit {
+			return i + 1
+		}
+	}
+	return len(p.limits) + 1
+}
+
+func (h *Handler) handleAir(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.findLocation(ctx, update, normalizeLocationName(update.Message.CommandArguments()))
+	if !ok {
+		return
+	}
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
+	if err != nil {
+		h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+		return
+	}
+
+	air, err := h.owProvider.AirPollution(weatherCtx, lat, lon)
+	if err != nil {
+		log.Println("error owProvider.AirPollution: ", err)
+		h.replyProviderError(ctx, update, err, "air.failed")
+		return
+	}
+
+	h.replyText(update, formatAir(langFrom(ctx), location.City, air, time.Now()))
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"study/weatherbot/alerts"
+	"study/weatherbot/i18n"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	maxAlertRules = 10
+
+	mphInMs = 0.44704
+)
+
+// handleAddAlert saves a rule like "/alert cold -10 dacha". Thresholds are
+// typed in the user's units and stored in metric ones.
+func (h *Handler) handleAddAlert(ctx context.Context, update tgbotapi.Update) {
+	args := strings.Fields(update.Message.CommandArguments())
+	if len(args) < 2 || len(args) > 3 {
+		h.reply(ctx, update, "alert.usage")
+		return
+	}
+
+	kind := strings.ToLower(args[0])
+	value, err := parseThreshold(args[1])
+	if err != nil {
+		h.reply(ctx, update, "alert.usage")
+		return
+	}
+
+	units := unitsFrom(ctx)
+	var threshold float64
+	switch kind {
+	case models.AlertCold:
+		threshold = celsiusFrom(units, value)
+	case models.AlertRain:
+		if value < 0 || value > 100 {
+			h.reply(ctx, update, "alert.rain_range")
+			return
+		}
+		threshold = value
+	case models.AlertWind:
+		if value <= 0 {
+			h.reply(ctx, update, "alert.usage")
+			return
+		}
+		threshold = metersPerSecondFrom(units, value)
+	default:
+		h.reply(ctx, update, "alert.usage")
+		return
+	}
+
+	var name string
+	if len(args) == 3 {
+		name = normalizeLocationName(args[2])
+	}
+	location, ok := h.findLocation(ctx, update, name)
+	if !ok {
+		return
+	}
+
+	rules, err := h.userRepo.ListAlertRules(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.ListAlertRules: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+	if len(rules) >= maxAlertRules {
+		h.reply(ctx, update, "alert.too_many", maxAlertRules)
+		return
+	}
+
+	// The evaluator only checks locations with coordinates.
+	if !location.Geocoded {
+		location.Lat, location.Lon, err = h.locationCoordinates(ctx, location)
+		if err != nil {
+			h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+			return
+		}
+	}
+
+	rule := models.AlertRule{
+		UserID:    update.Message.From.ID,
+		ChatID:    update.Message.Chat.ID,
+		Kind:      kind,
+		Threshold: threshold,
+		Location:  *location,
+	}
+	rule.ID, err = h.userRepo.AddAlertRule(ctx, rule)
+	if err != nil {
+		log.Println("error userRepo.AddAlertRule: ", err)
+		h.reply(ctx, update, "alert.failed")
+		return
+	}
+
+	h.reply(ctx, update, "alert.saved", rule.ID, location.Name, describeAlertRule(langFrom(ctx), units, rule))
+}
+
+func (h *Handler) handleListAlerts(ctx context.Context, update tgbotapi.Update) {
+	rules, err := h.userRepo.ListAlertRules(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.ListAlertRules: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if len(rules) == 0 {
+		h.reply(ctx, update, "alert.list_empty")
+		return
+	}
+
+	lang, units := langFrom(ctx), unitsFrom(ctx)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "alert.list_header"))
+	for _, rule := range rules {
+		sb.WriteString("\n")
+		sb.WriteString(i18n.T(lang, "alert.list_item", rule.ID, rule.Location.Name, describeAlertRule(lang, units, rule)))
+	}
+
+	h.replyText(update, sb.String())
+}
+
+func (h *Handler) handleDeleteAlert(ctx context.Context, update tgbotapi.Update) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(strings.TrimSpace(update.Message.CommandArguments()), "#"), 10, 64)
+	if err != nil {
+		h.reply(ctx, update, "alert.delete_usage")
+		return
+	}
+
+	deleted, err := h.userRepo.DeleteAlertRule(ctx, update.Message.From.ID, id)
+	if err != nil {
+		log.Println("error userRepo.DeleteAlertRule: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !deleted {
+		h.reply(ctx, update, "alert.not_found", id)
+		return
+	}
+
+	h.reply(ctx, update, "alert.deleted", id)
+}
+
+// AlertMessage builds the notification about a fired rule in the language
+// and units of the rule's user.
+func (h *Handler) AlertMessage(ctx context.Context, rule models.AlertRule, event alerts.Event) (string, error) {
+	lang, units, err := h.userPrefs(ctx, rule.UserID)
+	if err != nil {
+		return "", err
+	}
+
+	city := rule.Location.City
+	switch rule.Kind {
+	case models.AlertCold:
+		return i18n.T(lang, "alert.fired.cold", rule.ID, city, temperature(units, celsiusTo(units, event.Value))), nil
+	case models.AlertRain:
+		return i18n.T(lang, "alert.fired.rain", rule.ID, city, event.Time.Format("15:04"), round(event.Value)), nil
+	case models.AlertWind:
+		return i18n.T(lang, "alert.fired.wind", rule.ID, city, event.Time.Format("15:04"), speed(lang, units, metersPerSecondTo(units, event.Value))), nil
+	default:
+		return "", fmt.Errorf("unknown alert kind %q", rule.Kind)
+	}
+}
+
+// findLocation returns the named location, or the default one when name is
+// empty. The user is answered with the reason when it returns false.
+func (h *Handler) findLocation(ctx context.Context, update tgbotapi.Update, name string) (*models.Location, bool) {
+	var location *models.Location
+	var err error
+	if name == "" {
+		location, err = h.userRepo.GetDefaultLocation(ctx, update.Message.From.ID)
+	} else {
+		location, err = h.userRepo.GetLocation(ctx, update.Message.From.ID, name)
+	}
+	if err != nil {
+		log.Println("error userRepo.GetLocation: ", err)
+		h.reply(ctx, update, "error.generic")
+		return nil, false
+	}
+
+	if location == nil {
+		if name != "" {
+			h.reply(ctx, update, "location.not_found", name)
+			return nil, false
+		}
+		h.reply(ctx, update, "city.not_set")
+		return nil, false
+	}
+
+	return location, true
+}
+
+// describeAlertRule tells the condition of the rule in the user's units.
+func describeAlertRule(lang i18n.Lang, units weather.Units, rule models.AlertRule) string {
+	switch rule.Kind {
+	case models.AlertCold:
+		return i18n.T(lang, "alert.rule.cold", temperature(units, celsiusTo(units, rule.Threshold)))
+	case models.AlertRain:
+		return i18n.T(lang, "alert.rule.rain", round(rule.Threshold))
+	case models.AlertWind:
+		return i18n.T(lang, "alert.rule.wind", speed(lang, units, metersPerSecondTo(units, rule.Threshold)))
+	default:
+		return rule.Kind
+	}
+}
+
+// parseThreshold accepts the decimal comma and the minus sign people copy
+// from weather sites.
+func parseThreshold(s string) (float64, error) {
+	s = strings.NewReplacer(",", ".", "−", "-").Replace(s)
+	return strconv.ParseFloat(s, 64)
+}
+
+// celsiusFrom converts a temperature in units to °C.
+func celsiusFrom(units weather.Units, v float64) float64 {
+	switch units {
+	case weather.Imperial:
+		return (v - 32) * 5 / 9
+	case weather.Standard:
+		return v - 273.15
+	default:
+		return v
+	}
+}
+
+// celsiusTo converts a temperature in °C to units.
+func celsiusTo(units weather.Units, c float64) float64 {
+	switch units {
+	case weather.Imperial:
+		return c*9/5 + 32
+	case weather.Standard:
+		return c + 273.15
+	default:
+		return c
+	}
+}
+
+// metersPerSecondFrom converts a speed in units to m/s.
+func metersPerSecondFrom(units weather.Units, v float64) float64 {
+	if units == weather.Imperial {
+		return v * mphInMs
+	}
+	return v
+}
+
+// metersPerSecondTo converts a speed in m/s to units.
+func metersPerSecondTo(units weather.Units, ms float64) float64 {
+	if units == weather.Imperial {
+		return ms / mphInMs
+	}
+	return ms
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	cityCallbackPrefix = "city:"
+	cityChoiceTTL      = 10 * time.Minute
+)
+
+func (h *Handler) handleCallbackQuery(ctx context.Context, update tgbotapi.Update) {
+	query := update.CallbackQuery
+	if query.Message == nil {
+		h.answerCallback(ctx, query, "")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(query.Data, cityCallbackPrefix):
+		h.handleCityCallback(ctx, query)
+	case strings.HasPrefix(query.Data, startCallbackPrefix):
+		h.handleStartCallback(ctx, query)
+	default:
+		log.Printf("Unknown callback - [%s] %s", query.From.UserName, query.Data)
+		h.answerCallback(ctx, query, "")
+	}
+}
+
+func (h *Handler) handleCityCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	chatID, messageID := query.Message.Chat.ID, query.Message.MessageID
+
+	choice, err := h.userRepo.GetCityChoice(ctx, chatID, messageID)
+	if err != nil {
+		log.Println("error userRepo.GetCityChoice: ", err)
+		h.answerCallback(ctx, query, "error.generic")
+		return
+	}
+
+	if choice == nil {
+		h.answerCallback(ctx, query, "city.choice_expired")
+		return
+	}
+
+	if choice.UserID != query.From.ID {
+		h.answerCallback(ctx, query, "city.choice_not_yours")
+		return
+	}
+
+	i, err := strconv.Atoi(strings.TrimPrefix(query.Data, cityCallbackPrefix))
+	if err != nil || i < 0 || i >= len(choice.Cities) {
+		h.answerCallback(ctx, query, "")
+		return
+	}
+
+	// Only the replica that removes the choice saves it, a double tap
+	// handled elsewhere finds it gone.
+	deleted, err := h.userRepo.DeleteCityChoice(ctx, chatID, messageID)
+	if err != nil {
+		log.Println("error userRepo.DeleteCityChoice: ", err)
+		h.answerCallback(ctx, query, "error.generic")
+		return
+	}
+	if !deleted {
+		h.answerCallback(ctx, query, "city.choice_expired")
+		return
+	}
+
+	city := choice.Cities[i]
+	coord := weather.Coordinate{
+		Name:    city.City,
+		Country: city.Country,
+		State:   city.State,
+		Lat:     city.Lat,
+		Lon:     city.Lon,
+	}
+	text, saved := h.saveLocation(ctx, query.From.ID, choice.LocationName, coord)
+	h.answerCallback(ctx, query, "")
+
+	// Replacing the text also removes the keyboard so the choice can't be
+	// made twice.
+	if choice.LocationName != "" {
+		h.editText(query, text)
+		return
+	}
+
+	// An edited message can't remove the keyboard /start offers for sharing
+	// the location, so the default city is confirmed with a new message.
+	h.editText(query, cityLabel(coord))
+	h.sendText(chatID, text, tgbotapi.NewRemoveKeyboard(true))
+
+	if saved {
+		h.cityQuestionAnswered(ctx, chatID, query.From.ID)
+	}
+}
+
+// editText replaces the text of the message the query came from, removing
+// its keyboard.
+func (h *Handler) editText(query *tgbotapi.CallbackQuery, text string) {
+	_, err := h.bot.Send(tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text))
+	if err != nil {
+		log.Println("error bot.Send: ", err)
+	}
+}
+
+// answerCallback stops the button spinner, showing the translated message
+// key to the user unless it is empty.
+func (h *Handler) answerCallback(ctx context.Context, query *tgbotapi.CallbackQuery, key string) {
+	text := ""
+	if key != "" {
+		text = i18n.T(langFrom(ctx), key)
+	}
+	_, err := h.bot.Request(tgbotapi.NewCallback(query.ID, text))
+	if err != nil {
+		log.Println("error bot.Request: ", err)
+	}
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"study/weatherbot/models"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// dialogTTL is how long the bot waits for the answer to a question.
+const dialogTTL = 10 * time.Minute
+
+// Dialog states, what the bot is waiting for.
+const (
+	dialogCity  = "city"  // the default city, asked by /city
+	dialogStart = "start" // the default city, asked by /start
+)
+
+// startDialog stores that the bot asked the user a question, the next plain
+// text message of the user in the chat is handled as the answer, see
+// handleText. The user is answered with an error when it returns false.
+func (h *Handler) startDialog(ctx context.Context, update tgbotapi.Update, state string) bool {
+	err := h.userRepo.SetDialog(ctx, models.Dialog{
+		ChatID:    update.Message.Chat.ID,
+		UserID:    update.Message.From.ID,
+		State:     state,
+		ExpiresAt: time.Now().Add(dialogTTL),
+	})
+	if err != nil {
+		log.Println("error userRepo.SetDialog: ", err)
+		h.reply(ctx, update, "error.generic")
+		return false
+	}
+	return true
+}
+
+// endDialog forgets the question asked to the user in the chat, if any.
+func (h *Handler) endDialog(ctx context.Context, chatID int64, userID int64) {
+	_, err := h.userRepo.DeleteDialog(ctx, chatID, userID)
+	if err != nil {
+		log.Println("error userRepo.DeleteDialog: ", err)
+	}
+}
+
+// handleText answers the question asked to the user, text without a
+// question is not understood.
+func (h *Handler) handleText(ctx context.Context, update tgbotapi.Update) {
+	chatID, userID := update.Message.Chat.ID, update.Message.From.ID
+
+	dialog, err := h.userRepo.GetDialog(ctx, chatID, userID)
+	if err != nil {
+		log.Println("error userRepo.GetDialog: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	text := strings.TrimSpace(update.Message.Text)
+	if dialog == nil || text == "" {
+		h.reply(ctx, update, "message.use_commands")
+		return
+	}
+
+	switch dialog.State {
+	case dialogCity, dialogStart:
+		// The question stays open until the city is saved, so a typo can be
+		// corrected by sending the name again, see cityQuestionAnswered.
+		h.selectCity(ctx, update, text, "")
+	default:
+		log.Printf("Unknown dialog state %q of user %d", dialog.State, userID)
+		h.reply(ctx, update, "message.use_commands")
+		h.endDialog(ctx, chatID, userID)
+	}
+}
+
+// cityQuestionAnswered ends the question for the city once the default city
+// is saved, however it was given. The /start questions continue when they
+// asked for it.
+func (h *Handler) cityQuestionAnswered(ctx context.Context, chatID int64, userID int64) {
+	dialog, err := h.userRepo.GetDialog(ctx, chatID, userID)
+	if err != nil {
+		log.Println("error userRepo.GetDialog: ", err)
+		return
+	}
+	if dialog == nil || (dialog.State != dialogCity && dialog.State != dialogStart) {
+		return
+	}
+
+	h.endDialog(ctx, chatID, userID)
+	if dialog.State == dialogStart {
+		h.askLanguage(ctx, chatID, userID)
+	}
+}
+
+func (h *Handler) handleCancel(ctx context.Context, update tgbotapi.Update) {
+	deleted, err := h.userRepo.DeleteDialog(ctx, update.Message.Chat.ID, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.DeleteDialog: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !deleted {
+		h.reply(ctx, update, "cancel.nothing")
+		return
+	}
+
+	h.reply(ctx, update, "cancel.done")
+}
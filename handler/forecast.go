@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	defaultForecastDays = 3
+	maxForecastDays     = 5
+)
+
+func (h *Handler) handleSendForecast(ctx context.Context, update tgbotapi.Update) {
+	days := defaultForecastDays
+	if arg := strings.TrimSpace(update.Message.CommandArguments()); arg != "" {
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 1 || n > maxForecastDays {
+			h.reply(ctx, update, "forecast.usage", maxForecastDays)
+			return
+		}
+		days = n
+	}
+
+	location, err := h.userRepo.GetDefaultLocation(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.GetDefaultLocation: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if location == nil {
+		h.reply(ctx, update, "city.not_set")
+		return
+	}
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
+	if err != nil {
+		h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+		return
+	}
+
+	forecast, err := h.owProvider.Forecast(weatherCtx, lat, lon, weatherParams(ctx))
+	if err != nil {
+		log.Println("error owProvider.Forecast: ", err)
+		h.replyProviderError(ctx, update, err, "forecast.failed")
+		return
+	}
+
+	h.replyText(update, formatForecast(langFrom(ctx), unitsFrom(ctx), location.City, forecast.Days, days))
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/weather"
+	"time"
+)
+
+const windDirections = 8
+
+const (
+	hPaToMmHg  = 0.750062
+	hPaToInHg  = 0.0295300
+	metersInMi = 1609.344
+)
+
+func formatWeather(lang i18n.Lang, units weather.Units, city string, current weather.Weather) string {
+	lines := []string{
+		i18n.T(lang, "weather.header", city, temperature(units, current.Temp), current.Description),
+		i18n.T(lang, "weather.feels_like", temperature(units, current.FeelsLike)),
+		i18n.T(lang, "weather.humidity", current.Humidity),
+		i18n.T(lang, "weather.pressure", pressure(lang, units, current.Pressure)),
+	}
+
+	wind := i18n.T(lang, "weather.wind", speed(lang, units, current.WindSpeed), windDirection(lang, current.WindDeg))
+	if current.WindGust > current.WindSpeed {
+		wind += i18n.T(lang, "weather.gusts", speed(lang, units, current.WindGust))
+	}
+	lines = append(lines,
+		wind,
+		i18n.T(lang, "weather.clouds", current.Clouds),
+		i18n.T(lang, "weather.visibility", distance(lang, units, current.Visibility)),
+	)
+
+	if current.Rain1h > 0 {
+		lines = append(lines, i18n.T(lang, "weather.rain", current.Rain1h))
+	}
+	if current.Snow1h > 0 {
+		lines = append(lines, i18n.T(lang, "weather.snow", current.Snow1h))
+	}
+
+	lines = append(lines, i18n.T(lang, "weather.sun", current.Sunrise.Format("15:04"), current.Sunset.Format("15:04")))
+
+	return strings.Join(lines, "\n")
+}
+
+func formatForecast(lang i18n.Lang, units weather.Units, city string, forecast []weather.DailyForecast, days int) string {
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "forecast.header", city))
+
+	for i, day := range forecast {
+		if i == days {
+			break
+		}
+		sb.WriteString("\n")
+		sb.WriteString(i18n.T(lang, "forecast.day",
+			i18n.T(lang, fmt.Sprintf("weekday.%d", day.Date.Weekday())),
+			day.Date.Format("02.01"),
+			round(day.TempMin),
+			round(day.TempMax),
+			temperatureUnit(units),
+			day.Description,
+		))
+	}
+
+	return sb.String()
+}
+
+// formatWarning shows an official warning as issued, only the period is
+// formatted.
+func formatWarning(lang i18n.Lang, alert weather.Alert) string {
+	return i18n.T(lang, "warning.item",
+		alert.Event,
+		alert.Start.Format("02.01 15:04"),
+		alert.End.Format("02.01 15:04"),
+		alert.Sender,
+		strings.TrimSpace(alert.Description),
+	)
+}
+
+func formatAir(lang i18n.Lang, city string, air weather.AirPollution, now time.Time) string {
+	lines := []string{i18n.T(lang, "air.header", city, airLevel(lang, air.Current.AQI), air.Current.AQI)}
+
+	for _, p := range pollutants {
+		v := p.value(air.Current)
+		lines = append(lines, i18n.T(lang, "air.component", p.name, v, airLevel(lang, p.level(v))))
+	}
+
+	// The worst hour ahead tells whether it's better to stay in today.
+	worst := 0
+	for _, q := range air.Forecast {
+		if q.Time.After(now) && q.Time.Before(now.Add(airForecastWindow)) && q.AQI > worst {
+			worst = q.AQI
+		}
+	}
+	if worst > 0 {
+		lines = append(lines, i18n.T(lang, "air.forecast", airLevel(lang, worst), worst))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// formatRain answers whether it will rain in the next two hours with a bar
+// per ten minutes and a summary of when it starts or stops.
+func formatRain(lang i18n.Lang, city string, nowcast weather.Nowcast, now time.Time) string {
+	start, intensities := rainTimeline(nowcast, now)
+	if len(intensities) == 0 {
+		return i18n.T(lang, "rain.failed")
+	}
+
+	var bars strings.Builder
+	for _, intensity := range intensities {
+		bars.WriteString(rainBar(intensity))
+	}
+	end := start.Add(time.Duration(len(intensities)) * rainSlot)
+
+	lines := []string{
+		i18n.T(lang, "rain.header", city),
+		fmt.Sprintf("%s %s %s", start.Format("15:04"), bars.String(), end.Format("15:04")),
+		rainSummary(lang, start, end, intensities),
+	}
+
+	chance := 0.0
+	for _, h := range nowcast.Hours {
+		if h.Time.Add(time.Hour).After(start) && h.Time.Before(end) {
+			chance = max(chance, h.Pop)
+		}
+	}
+	if len(nowcast.Hours) > 0 {
+		lines = append(lines, i18n.T(lang, "rain.chance", round(chance*100)))
+	}
+
+	lines = append(lines, i18n.T(lang, "rain.legend"))
+	return strings.Join(lines, "\n")
+}
+
+func rainSummary(lang i18n.Lang, start time.Time, end time.Time, intensities []float64) string {
+	raining := isRaining(intensities[0])
+	for i, intensity := range intensities {
+		if isRaining(intensity) == raining {
+			continue
+		}
+		at := start.Add(time.Duration(i) * rainSlot).Format("15:04")
+		if raining {
+			return i18n.T(lang, "rain.stops", at)
+		}
+		return i18n.T(lang, "rain.starts", at)
+	}
+
+	if raining {
+		return i18n.T(lang, "rain.continues", end.Format("15:04"))
+	}
+	return i18n.T(lang, "rain.none")
+}
+
+func airLevel(lang i18n.Lang, aqi int) string {
+	return i18n.T(lang, fmt.Sprintf("air.level.%d", aqi))
+}
+
+// temperature formats a temperature the provider returned in units.
+func temperature(units weather.Units, v float64) string {
+	return fmt.Sprintf("%d%s", round(v), temperatureUnit(units))
+}
+
+// temperatureUnit returns the suffix of temperatures in units.
+func temperatureUnit(units weather.Units) string {
+	switch units {
+	case weather.Imperial:
+		return "°F"
+	case weather.Standard:
+		return " K"
+	default:
+		return "°C"
+	}
+}
+
+// speed formats a wind speed the provider returned in units, it is in miles
+// per hour for imperial units and in metres per second otherwise.
+func speed(lang i18n.Lang, units weather.Units, v float64) string {
+	if units == weather.Imperial {
+		return i18n.T(lang, "unit.speed.mph", round(v))
+	}
+	return i18n.T(lang, "unit.speed.ms", round(v))
+}
+
+// pressure formats a pressure in hPa, which the provider returns in any
+// units, in the units usual for the unit system. Metric pressure is in hPa,
+// the SI-derived unit weather services use, except in Russian where
+// forecasts traditionally give it in mmHg.
+func pressure(lang i18n.Lang, units weather.Units, hPa int) string {
+	switch {
+	case units == weather.Imperial:
+		return i18n.T(lang, "unit.pressure.inhg", float64(hPa)*hPaToInHg)
+	case units == weather.Metric && lang == i18n.Russian:
+		return i18n.T(lang, "unit.pressure.mmhg", round(float64(hPa)*hPaToMmHg))
+	default:
+		return i18n.T(lang, "unit.pressure.hpa", hPa)
+	}
+}
+
+// distance formats a distance in metres.
+func distance(lang i18n.Lang, units weather.Units, meters int) string {
+	if units == weather.Imperial {
+		return i18n.T(lang, "unit.distance.mi", float64(meters)/metersInMi)
+	}
+	return i18n.T(lang, "unit.distance.km", float64(meters)/1000)
+}
+
+func windDirection(lang i18n.Lang, deg int) string {
+	return i18n.T(lang, fmt.Sprintf("wind.%d", ((deg%360)+22)/45%windDirections))
+}
+
+func round(v float64) int {
+	return int(math.Round(v))
+}
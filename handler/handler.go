@@ -7,225 +7,359 @@ import (
 	"log"
 	"math"
 	"strings"
-	"study/weatherbot/clients/openweather"
+	"study/weatherbot/i18n"
 	"study/weatherbot/models"
+	"study/weatherbot/weather"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type userRepository interface {
-	GetUserCity(ctx context.Context, userID int64) (string, error)
-	CreateUser(ctx context.Context, userID int64) error
-	UpdateUserCity(ctx context.Context, userID int64, city string) error
+	CreateUser(ctx context.Context, userID int64, language string) error
+	UpdateUserLanguage(ctx context.Context, userID int64, language string) error
+	UpdateUserUnits(ctx context.Context, userID int64, units string) error
+	UpdateUserCity(ctx context.Context, place models.Location) (string, error)
 	GetUser(ctx context.Context, userID int64) (*models.User, error)
+	GetDefaultLocation(ctx context.Context, userID int64) (*models.Location, error)
+	GetLocation(ctx context.Context, userID int64, name string) (*models.Location, error)
+	ListLocations(ctx context.Context, userID int64) ([]models.Location, error)
+	AddLocation(ctx context.Context, location models.Location) error
+	DeleteLocation(ctx context.Context, userID int64, name string) (bool, error)
+	SetDefaultLocation(ctx context.Context, userID int64, name string) (bool, error)
+	UpsertSubscription(ctx context.Context, sub models.Subscription) error
+	DeleteSubscription(ctx context.Context, userID int64) (bool, error)
+	AddAlertRule(ctx context.Context, rule models.AlertRule) (int64, error)
+	ListAlertRules(ctx context.Context, userID int64) ([]models.AlertRule, error)
+	DeleteAlertRule(ctx context.Context, userID int64, ruleID int64) (bool, error)
+	EnableWarnings(ctx context.Context, userID int64, chatID int64) error
+	DisableWarnings(ctx context.Context, userID int64) (bool, error)
+	CompleteOnboarding(ctx context.Context, userID int64) error
+	SetDialog(ctx context.Context, dialog models.Dialog) error
+	GetDialog(ctx context.Context, chatID int64, userID int64) (*models.Dialog, error)
+	DeleteDialog(ctx context.Context, chatID int64, userID int64) (bool, error)
+	SaveCityChoice(ctx context.Context, choice models.CityChoice) error
+	GetCityChoice(ctx context.Context, chatID int64, messageID int) (*models.CityChoice, error)
+	DeleteCityChoice(ctx context.Context, chatID int64, messageID int) (bool, error)
 }
 
 type weatherProvider interface {
-	Coordinates(ctx context.Context, city string) (openweather.Coordinate, error)
-	Weather(ctx context.Context, lat float64, lon float64) (openweather.Weather, error)
+	Coordinates(ctx context.Context, city string) (weather.Coordinate, error)
+	Cities(ctx context.Context, city string) ([]weather.Coordinate, error)
+	ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error)
+	Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error)
+	Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error)
+	Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error)
+	AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error)
+	Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error)
 }
 
 type botAPI interface {
 	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
 	GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel
 	StopReceivingUpdates()
 }
 
 type Handler struct {
-	bot        botAPI
-	owProvider weatherProvider
-	userRepo   userRepository
+	bot         botAPI
+	owProvider  weatherProvider
+	userRepo    userRepository
+	userLimiter *userLimiter // nil when users are not limited
+	workers     int          // number of updates handled at the same time
+	admins      map[int64]bool
+	stats       map[string]func() any // reported by /stats by name
+
+	router   *router
+	dispatch handlerFunc // dispatchUpdate wrapped in the middleware all updates go through
 }
 
-func New(bot botAPI, owProvider weatherProvider, userRepo userRepository) *Handler {
-	return &Handler{
+// Option configures the handler created by New.
+type Option func(*Handler)
+
+func New(bot botAPI, owProvider weatherProvider, userRepo userRepository, opts ...Option) *Handler {
+	h := &Handler{
 		bot:        bot,
 		owProvider: owProvider,
 		userRepo:   userRepo,
+		workers:    defaultWorkers,
+		admins:     make(map[int64]bool),
+		stats:      make(map[string]func() any),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.router = newRouter(h.handleUnknownCommand, h.reply)
+	h.registerCommands()
+	h.dispatch = chain(h.dispatchUpdate, h.recoverPanic, h.logUpdate, h.limitUser, h.loadUser)
+	return h
+}
+
+// registerCommands registers the bot commands in the order they are listed
+// to users.
+func (h *Handler) registerCommands() {
+	for _, c := range []command{
+		{name: "start", handle: h.handleStart},
+		{name: "help", handle: h.handleHelp},
+		{name: "weather", args: "[place]", handle: h.handleSendWeather},
+		{name: "forecast", args: "[days]", handle: h.handleSendForecast},
+		{name: "rain", args: "[place]", handle: h.handleRain},
+		{name: "air", args: "[place]", handle: h.handleAir},
+		{name: "warnings", args: "[place|on|off]", handle: h.handleWarnings},
+		{name: "city", args: "[city]", handle: h.handleSetCity},
+		{name: "addcity", args: "<name> <city>", usage: "location.add_usage", handle: h.handleAddLocation},
+		{name: "cities", handle: h.handleListLocations},
+		{name: "delcity", args: "<name>", usage: "location.delete_usage", handle: h.handleDeleteLocation},
+		{name: "default", args: "<name>", usage: "location.default_usage", handle: h.handleSetDefaultLocation},
+		{name: "subscribe", args: "<HH:MM>", usage: "subscribe.usage", handle: h.handleSubscribe},
+		{name: "unsubscribe", handle: h.handleUnsubscribe},
+		{name: "alert", args: "<condition> <threshold> [place]", usage: "alert.usage", handle: h.handleAddAlert},
+		{name: "alerts", handle: h.handleListAlerts},
+		{name: "delalert", args: "<number>", usage: "alert.delete_usage", handle: h.handleDeleteAlert},
+		{name: "lang", args: "[language]", handle: h.handleLang},
+		{name: "units", args: "[metric|imperial|kelvin]", handle: h.handleUnits},
+		{name: "cancel", handle: h.handleCancel},
+		{name: "stats", handle: h.handleStats, middleware: []middleware{h.adminOnly}, hidden: true},
+	} {
+		h.router.register(c)
 	}
 }
 
+// handleUpdate passes an update with a sender to the middleware all updates
+// go through, see dispatchUpdate.
 func (h *Handler) handleUpdate(ctx context.Context, update tgbotapi.Update) {
-	if update.Message == nil {
+	if update.CallbackQuery == nil && update.Message == nil {
 		return
 	}
-
-	if update.Message.IsCommand() {
-		err := h.ensureUser(ctx, update)
-		if err != nil {
-			log.Println("error h.ensureUser: ", err)
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошлла ошибка")
-			msg.ReplyToMessageID = update.Message.MessageID
-			h.bot.Send(msg)
-			return
-		}
-		switch update.Message.Command() {
-		case "city":
-			h.handleSetCity(ctx, update)
-			return
-		case "weather":
-			h.handleSendWeather(ctx, update)
-			return
-		default:
-			h.handleUnknownCommand(update)
-			return
-		}
+	if update.SentFrom() == nil {
+		return
 	}
 
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Воcпользуйтесь доступными командами")
-	msg.ReplyToMessageID = update.Message.MessageID
-	h.bot.Send(msg)
+	h.dispatch(ctx, update)
+}
+
+// dispatchUpdate handles an update after the middleware: the user's
+// language and units and whether they are onboarding are in ctx, see
+// loadUser.
+func (h *Handler) dispatchUpdate(ctx context.Context, update tgbotapi.Update) {
+	switch {
+	case update.CallbackQuery != nil:
+		h.handleCallbackQuery(ctx, update)
+	case update.Message.IsCommand():
+		h.router.route(ctx, update)
+	case update.Message.Location != nil:
+		h.handleSharedLocation(ctx, update)
+	default:
+		h.handleText(ctx, update)
+	}
 }
 
+// Start receives updates with long polling until ctx is cancelled.
 func (h *Handler) Start(ctx context.Context) {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates := h.bot.GetUpdatesChan(u)
 
+	log.Println("Bot handler started...")
+
+	h.serve(ctx, updates)
+	h.bot.StopReceivingUpdates()
+
+	log.Println("Bot handler stopped gracefully.")
+}
+
+// serve handles updates until ctx is cancelled or updates is closed and
+// then drains the queued ones for up to drainTimeout. Updates of a chat
+// always go to the same worker, so they are handled in the order they were
+// sent, while other chats are handled in parallel. When the queue of a
+// worker is full no more updates are read.
+func (h *Handler) serve(ctx context.Context, updates <-chan tgbotapi.Update) {
+	// Updates are read already, so they are handled even if shutdown starts
+	// meanwhile, until the drain times out.
+	handleCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancel()
+
+	var dropped atomic.Int64
 	var wg sync.WaitGroup
+	queues := make([]chan tgbotapi.Update, h.workers)
+	for i := range queues {
+		queues[i] = make(chan tgbotapi.Update, workerQueueSize)
+		wg.Add(1)
+		go func(queue <-chan tgbotapi.Update) {
+			defer wg.Done()
+			for update := range queue {
+				if handleCtx.Err() != nil {
+					dropped.Add(1)
+					continue
+				}
+				h.handleUpdate(handleCtx, update)
+			}
+		}(queues[i])
+	}
+	defer func() {
+		for _, queue := range queues {
+			close(queue)
+		}
+		timer := time.AfterFunc(drainTimeout, cancel)
+		defer timer.Stop()
+		wg.Wait()
 
-	log.Println("Bot handler started...")
+		if n := dropped.Load(); n > 0 {
+			log.Printf("Dropped %d updates on shutdown", n)
+		}
+	}()
 
 	for {
 		select {
 		case <-ctx.Done():
 			log.Println("Stopping bot handler...")
-			h.bot.StopReceivingUpdates()
-			wg.Wait()
-			log.Println("Bot handler stopped gracefully.")
 			return
 		case update, ok := <-updates:
 			if !ok {
-				wg.Wait()
 				return
 			}
-			wg.Add(1)
-			go func(upd tgbotapi.Update) {
-				defer wg.Done()
-				h.handleUpdate(ctx, upd)
-			}(update)
+			select {
+			case queues[shard(update, len(queues))] <- update:
+			case <-ctx.Done():
+				// The worker of the chat is busy, the update can't wait.
+				dropped.Add(1)
+				log.Println("Stopping bot handler...")
+				return
+			}
 		}
 	}
 }
 
+// shard returns the worker for the chat of the update, updates without a
+// chat are spread by sender.
+func shard(update tgbotapi.Update, workers int) int {
+	var id int64
+	if chat := update.FromChat(); chat != nil {
+		id = chat.ID
+	} else if from := update.SentFrom(); from != nil {
+		id = from.ID
+	}
+	return int(uint64(id) % uint64(workers))
+}
+
 func (h *Handler) handleSetCity(ctx context.Context, update tgbotapi.Update) {
 	cityInput := strings.TrimSpace(update.Message.CommandArguments())
 	if cityInput == "" {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Пожалуйста, укажите город: /city <название>")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
+		if h.startDialog(ctx, update, dialogCity) {
+			h.reply(ctx, update, "city.ask")
+		}
 		return
 	}
 
-	if len(cityInput) < 2 {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Название города слишком короткое")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
+	h.selectCity(ctx, update, cityInput, "")
+}
+
+func (h *Handler) handleSendWeather(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.findLocation(ctx, update, normalizeLocationName(update.Message.CommandArguments()))
+	if !ok {
 		return
 	}
 
-	// Validate city existence and get normalized name
 	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	coord, err := h.owProvider.Coordinates(weatherCtx, cityInput)
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
 	if err != nil {
-		if errors.Is(err, openweather.ErrCityNotFound) {
-			msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Город '%s' не найден. Пожалуйста, проверьте правильность написания.", cityInput))
-			msg.ReplyToMessageID = update.Message.MessageID
-			h.bot.Send(msg)
-			return
-		}
-		log.Println("error owProvider.Coordinates: ", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошла ошибка при проверке города. Попробуйте позже.")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
+		h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
 		return
 	}
 
-	err = h.userRepo.UpdateUserCity(ctx, update.Message.From.ID, coord.Name)
+	current, err := h.owProvider.Weather(weatherCtx, lat, lon, weatherParams(ctx))
 	if err != nil {
-		log.Println("error userRepo.updateUserCity: ", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Ошибка при попытке сохранения города - %s", coord.Name))
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
+		h.replyProviderError(ctx, update, err, "weather.failed")
 		return
 	}
 
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, fmt.Sprintf("Город %s успешно сохранен", coord.Name))
-	msg.ReplyToMessageID = update.Message.MessageID
-	h.bot.Send(msg)
+	h.replyText(update, formatWeather(langFrom(ctx), unitsFrom(ctx), location.City, current))
 }
 
-func (h *Handler) handleSendWeather(ctx context.Context, update tgbotapi.Update) {
-	city, err := h.userRepo.GetUserCity(ctx, update.Message.From.ID)
+func (h *Handler) handleUnknownCommand(ctx context.Context, update tgbotapi.Update) {
+	h.reply(ctx, update, "command.unknown")
+}
+
+// ensureUser returns the user the update came from, new users are created
+// with the language of their Telegram client.
+func (h *Handler) ensureUser(ctx context.Context, from *tgbotapi.User) (*models.User, error) {
+	user, err := h.userRepo.GetUser(ctx, from.ID)
 	if err != nil {
-		log.Println("error userRepo.updateUserCity: ", err)
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Произошлла ошибка")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
-		return
+		return nil, fmt.Errorf("error userRepo.GetUser: %w", err)
 	}
 
-	if city == "" {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Сначала сохраните ваш город - /city <your city>")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
-		return
+	if user == nil {
+		user = &models.User{
+			ID:       from.ID,
+			Language: string(i18n.FromLanguageCode(from.LanguageCode)),
+			Units:    string(weather.Metric),
+		}
+		err = h.userRepo.CreateUser(ctx, user.ID, user.Language)
+
+		if err != nil {
+			return nil, fmt.Errorf("error userRepo.CreateUser: %w", err)
+		}
+
 	}
+	return user, nil
+}
 
-	log.Printf("[%s] %s", update.Message.From.UserName, update.Message.Text)
+// weatherParams asks the provider for descriptions in the user's language
+// and measurements in the user's units.
+func weatherParams(ctx context.Context) weather.Params {
+	return weather.Params{Lang: string(langFrom(ctx)), Units: unitsFrom(ctx)}
+}
 
-	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+// reply answers the message with the message key translated to the user's
+// language.
+func (h *Handler) reply(ctx context.Context, update tgbotapi.Update, key string, args ...any) {
+	h.replyText(update, i18n.T(langFrom(ctx), key, args...))
+}
 
-	coordinate, err := h.owProvider.Coordinates(weatherCtx, city)
-	if err != nil {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не смогли получить координаты")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
-		return
-	}
+// replyProviderError tells the user why the weather provider failed when
+// the reason is known, otherwise it replies with key.
+func (h *Handler) replyProviderError(ctx context.Context, update tgbotapi.Update, err error, key string) {
+	key, args := providerErrorMessage(err, key)
+	h.reply(ctx, update, key, args...)
+}
 
-	weather, err := h.owProvider.Weather(weatherCtx, coordinate.Lat, coordinate.Lon)
-	if err != nil {
-		msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Не смогли получить погоду в этой местности")
-		msg.ReplyToMessageID = update.Message.MessageID
-		h.bot.Send(msg)
-		return
+// providerErrorMessage returns the message key and arguments explaining the
+// failure of the weather provider, key when the reason is unknown.
+func providerErrorMessage(err error, key string) (string, []any) {
+	var statusErr *weather.StatusError
+	switch {
+	case errors.Is(err, weather.ErrUnauthorized):
+		return "provider.unauthorized", nil
+	case errors.Is(err, weather.ErrRateLimited):
+		var retryAfter time.Duration
+		if errors.As(err, &statusErr) {
+			retryAfter = statusErr.RetryAfter
+		}
+		return "provider.rate_limited", []any{max(1, int(math.Ceil(retryAfter.Minutes())))}
+	case errors.Is(err, weather.ErrUpstream), errors.Is(err, context.DeadlineExceeded):
+		return "provider.upstream", nil
+	default:
+		return key, nil
 	}
+}
 
-	msg := tgbotapi.NewMessage(
-		update.Message.Chat.ID,
-		fmt.Sprintf("Температура в вашем городе \n%s: %d°C", city, int(math.Round(weather.Temp))),
-	)
+func (h *Handler) replyText(update tgbotapi.Update, text string) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 	msg.ReplyToMessageID = update.Message.MessageID
 	h.bot.Send(msg)
 }
 
-func (h *Handler) handleUnknownCommand(update tgbotapi.Update) {
-	log.Printf("Unknown command - [%s] %s", update.Message.From.UserName, update.Message.Text)
-	msg := tgbotapi.NewMessage(update.Message.Chat.ID, "Такая команда не доступна")
+// replyRemovingKeyboard answers the message with the text and removes the
+// keyboard /start offers for sharing the location, if it is shown.
+func (h *Handler) replyRemovingKeyboard(update tgbotapi.Update, text string) {
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, text)
 	msg.ReplyToMessageID = update.Message.MessageID
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
 	h.bot.Send(msg)
 }
-
-func (h *Handler) ensureUser(ctx context.Context, update tgbotapi.Update) error {
-	user, err := h.userRepo.GetUser(ctx, update.Message.From.ID)
-	if err != nil {
-		return fmt.Errorf("error userRepo.GetUser: %w", err)
-	}
-
-	if user == nil {
-		err = h.userRepo.CreateUser(ctx, update.Message.From.ID)
-
-		if err != nil {
-			return fmt.Errorf("error userRepo.CreateUser: %w", err)
-		}
-
-	}
-	return nil
-}
@@ -2,53 +2,261 @@ package handler
 
 import (
 	"context"
-	"study/weatherbot/clients/openweather"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"study/weatherbot/alerts"
+	"study/weatherbot/i18n"
 	"study/weatherbot/models"
+	"study/weatherbot/weather"
+	"sync"
 	"testing"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 type mockUserRepo struct {
-	city string
-	err  error
-	user *models.User
+	location  *models.Location // default location
+	locations []models.Location
+	err       error
+	user      *models.User
+	sub       *models.Subscription
+	rules     []models.AlertRule
+	warnings  bool
+	dialog    *models.Dialog
+	choices   map[int]models.CityChoice // by message ID
+	choiceErr error                     // returned by SaveCityChoice
 }
 
-func (m *mockUserRepo) GetUserCity(ctx context.Context, userID int64) (string, error) {
-	return m.city, m.err
+func (m *mockUserRepo) CreateUser(ctx context.Context, userID int64, language string) error {
+	m.user = &models.User{ID: userID, Language: language}
+	return m.err
 }
-func (m *mockUserRepo) CreateUser(ctx context.Context, userID int64) error {
+func (m *mockUserRepo) UpdateUserLanguage(ctx context.Context, userID int64, language string) error {
+	m.user.Language = language
 	return m.err
 }
-func (m *mockUserRepo) UpdateUserCity(ctx context.Context, userID int64, city string) error {
-	m.city = city
+func (m *mockUserRepo) UpdateUserUnits(ctx context.Context, userID int64, units string) error {
+	m.user.Units = units
 	return m.err
 }
+func (m *mockUserRepo) UpdateUserCity(ctx context.Context, place models.Location) (string, error) {
+	place.Name = models.DefaultLocationName
+	if m.location != nil && m.location.Name != "" {
+		place.Name = m.location.Name
+	}
+	m.location = &place
+	return place.Name, m.err
+}
 func (m *mockUserRepo) GetUser(ctx context.Context, userID int64) (*models.User, error) {
 	return m.user, m.err
 }
+func (m *mockUserRepo) GetDefaultLocation(ctx context.Context, userID int64) (*models.Location, error) {
+	return m.location, m.err
+}
+func (m *mockUserRepo) GetLocation(ctx context.Context, userID int64, name string) (*models.Location, error) {
+	for i := range m.locations {
+		if m.locations[i].Name == name {
+			return &m.locations[i], m.err
+		}
+	}
+	return nil, m.err
+}
+func (m *mockUserRepo) ListLocations(ctx context.Context, userID int64) ([]models.Location, error) {
+	return m.locations, m.err
+}
+func (m *mockUserRepo) AddLocation(ctx context.Context, location models.Location) error {
+	for i := range m.locations {
+		if m.locations[i].Name == location.Name {
+			location.IsDefault = m.locations[i].IsDefault
+			m.locations[i] = location
+			return m.err
+		}
+	}
+	location.IsDefault = len(m.locations) == 0
+	m.locations = append(m.locations, location)
+	return m.err
+}
+func (m *mockUserRepo) DeleteLocation(ctx context.Context, userID int64, name string) (bool, error) {
+	for i := range m.locations {
+		if m.locations[i].Name == name {
+			m.locations = append(m.locations[:i], m.locations[i+1:]...)
+			return true, m.err
+		}
+	}
+	return false, m.err
+}
+func (m *mockUserRepo) SetDefaultLocation(ctx context.Context, userID int64, name string) (bool, error) {
+	found := false
+	for i := range m.locations {
+		m.locations[i].IsDefault = m.locations[i].Name == name
+		found = found || m.locations[i].IsDefault
+	}
+	return found, m.err
+}
+func (m *mockUserRepo) UpsertSubscription(ctx context.Context, sub models.Subscription) error {
+	m.sub = &sub
+	return m.err
+}
+func (m *mockUserRepo) DeleteSubscription(ctx context.Context, userID int64) (bool, error) {
+	deleted := m.sub != nil
+	m.sub = nil
+	return deleted, m.err
+}
+
+func (m *mockUserRepo) AddAlertRule(ctx context.Context, rule models.AlertRule) (int64, error) {
+	rule.ID = int64(len(m.rules) + 1)
+	m.rules = append(m.rules, rule)
+	return rule.ID, m.err
+}
+func (m *mockUserRepo) ListAlertRules(ctx context.Context, userID int64) ([]models.AlertRule, error) {
+	return m.rules, m.err
+}
+func (m *mockUserRepo) DeleteAlertRule(ctx context.Context, userID int64, ruleID int64) (bool, error) {
+	for i := range m.rules {
+		if m.rules[i].ID == ruleID {
+			m.rules = append(m.rules[:i], m.rules[i+1:]...)
+			return true, m.err
+		}
+	}
+	return false, m.err
+}
+
+func (m *mockUserRepo) EnableWarnings(ctx context.Context, userID int64, chatID int64) error {
+	m.warnings = true
+	return m.err
+}
+func (m *mockUserRepo) DisableWarnings(ctx context.Context, userID int64) (bool, error) {
+	disabled := m.warnings
+	m.warnings = false
+	return disabled, m.err
+}
+func (m *mockUserRepo) CompleteOnboarding(ctx context.Context, userID int64) error {
+	m.user.OnboardedAt = time.Now()
+	return m.err
+}
+func (m *mockUserRepo) SetDialog(ctx context.Context, dialog models.Dialog) error {
+	m.dialog = &dialog
+	return m.err
+}
+func (m *mockUserRepo) GetDialog(ctx context.Context, chatID int64, userID int64) (*models.Dialog, error) {
+	if m.dialog == nil || m.dialog.ChatID != chatID || m.dialog.UserID != userID || time.Now().After(m.dialog.ExpiresAt) {
+		return nil, m.err
+	}
+	return m.dialog, m.err
+}
+func (m *mockUserRepo) SaveCityChoice(ctx context.Context, choice models.CityChoice) error {
+	if m.choices == nil {
+		m.choices = make(map[int]models.CityChoice)
+	}
+	if m.choiceErr != nil {
+		return m.choiceErr
+	}
+	m.choices[choice.MessageID] = choice
+	return m.err
+}
+func (m *mockUserRepo) GetCityChoice(ctx context.Context, chatID int64, messageID int) (*models.CityChoice, error) {
+	choice, ok := m.choices[messageID]
+	if !ok || choice.ChatID != chatID || time.Now().After(choice.ExpiresAt) {
+		return nil, m.err
+	}
+	return &choice, m.err
+}
+func (m *mockUserRepo) DeleteCityChoice(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	choice, _ := m.GetCityChoice(ctx, chatID, messageID)
+	delete(m.choices, messageID)
+	return choice != nil, m.err
+}
+func (m *mockUserRepo) DeleteDialog(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	dialog, _ := m.GetDialog(ctx, chatID, userID)
+	m.dialog = nil
+	return dialog != nil, m.err
+}
 
 type mockWeatherProvider struct {
-	coord   openweather.Coordinate
-	weather openweather.Weather
-	err     error
+	coordCalls int
+	coord      weather.Coordinate
+	cities     []weather.Coordinate
+	weather    weather.Weather
+	forecast   weather.Forecast
+	alerts     []weather.Alert
+	air        weather.AirPollution
+	nowcast    weather.Nowcast
+	params     weather.Params
+	reverseErr error
+	err        error
 }
 
-func (m *mockWeatherProvider) Coordinates(ctx context.Context, city string) (openweather.Coordinate, error) {
+func (m *mockWeatherProvider) Coordinates(ctx context.Context, city string) (weather.Coordinate, error) {
+	m.coordCalls++
+	return m.coord, m.err
+}
+func (m *mockWeatherProvider) Cities(ctx context.Context, city string) ([]weather.Coordinate, error) {
+	if m.cities == nil {
+		return []weather.Coordinate{m.coord}, m.err
+	}
+	return m.cities, m.err
+}
+func (m *mockWeatherProvider) ReverseGeocode(ctx context.Context, lat float64, lon float64) (weather.Coordinate, error) {
+	if m.reverseErr != nil {
+		return weather.Coordinate{}, m.reverseErr
+	}
 	return m.coord, m.err
 }
-func (m *mockWeatherProvider) Weather(ctx context.Context, lat float64, lon float64) (openweather.Weather, error) {
+func (m *mockWeatherProvider) Weather(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Weather, error) {
+	m.params = params
 	return m.weather, m.err
 }
+func (m *mockWeatherProvider) Forecast(ctx context.Context, lat float64, lon float64, params weather.Params) (weather.Forecast, error) {
+	m.params = params
+	return m.forecast, m.err
+}
+
+func (m *mockWeatherProvider) Alerts(ctx context.Context, lat float64, lon float64) ([]weather.Alert, error) {
+	return m.alerts, m.err
+}
+
+func (m *mockWeatherProvider) AirPollution(ctx context.Context, lat float64, lon float64) (weather.AirPollution, error) {
+	return m.air, m.err
+}
+
+func (m *mockWeatherProvider) Nowcast(ctx context.Context, lat float64, lon float64) (weather.Nowcast, error) {
+	return m.nowcast, m.err
+}
 
 type mockBotAPI struct {
-	sent []tgbotapi.Chattable
+	jitter  time.Duration // upper bound of a random delay of Send
+	release chan struct{} // Send waits for it when set
+
+	mu       sync.Mutex
+	sent     []tgbotapi.Chattable
+	requests []tgbotapi.Chattable
 }
 
 func (m *mockBotAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	if m.jitter > 0 {
+		time.Sleep(rand.N(m.jitter))
+	}
+	if m.release != nil {
+		<-m.release
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
 	m.sent = append(m.sent, c)
-	return tgbotapi.Message{}, nil
+	return tgbotapi.Message{MessageID: len(m.sent)}, nil
+}
+func (m *mockBotAPI) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = append(m.requests, c)
+	return &tgbotapi.APIResponse{Ok: true}, nil
 }
 func (m *mockBotAPI) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.UpdatesChannel {
 	return nil
@@ -56,13 +264,13 @@ func (m *mockBotAPI) GetUpdatesChan(config tgbotapi.UpdateConfig) tgbotapi.Updat
 func (m *mockBotAPI) StopReceivingUpdates() {}
 
 func TestHandler_HandleUpdate_SetCity(t *testing.T) {
-	repo := &mockUserRepo{user: &models.User{ID: 1}}
-	weather := &mockWeatherProvider{
-		coord: openweather.Coordinate{Name: "Moscow", Lat: 55, Lon: 37},
+	repo := &mockUserRepo{user: &models.User{ID: 1, OnboardedAt: time.Now()}}
+	provider := &mockWeatherProvider{
+		coord: weather.Coordinate{Name: "Moscow", Lat: 55, Lon: 37},
 	}
 	bot := &mockBotAPI{}
 
-	h := New(bot, weather, repo)
+	h := New(bot, provider, repo)
 
 	update := tgbotapi.Update{
 		Message: &tgbotapi.Message{
@@ -75,23 +283,52 @@ func TestHandler_HandleUpdate_SetCity(t *testing.T) {
 
 	h.handleUpdate(context.Background(), update)
 
-	if repo.city != "Moscow" {
-		t.Errorf("got city %v, want Moscow", repo.city)
+	if repo.location == nil || repo.location.City != "Moscow" || repo.location.Lat != 55 {
+		t.Errorf("got location %+v, want Moscow", repo.location)
 	}
 	if len(bot.sent) != 1 {
-		t.Errorf("got %d messages sent, want 1", len(bot.sent))
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+	}
+	if got := bot.sent[0].(tgbotapi.MessageConfig).Text; got != "Город Moscow успешно сохранен" {
+		t.Errorf("got %q, want the city saved", got)
+	}
+
+	// Another default place than home is named, so the user knows it moved.
+	repo.location = &models.Location{UserID: 1, Name: "office", City: "Tver", IsDefault: true}
+	bot.sent = nil
+	h.handleUpdate(context.Background(), update)
+
+	if len(bot.sent) != 1 {
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+	}
+	if got := bot.sent[0].(tgbotapi.MessageConfig).Text; got != "Город Moscow сохранен для места по умолчанию office" {
+		t.Errorf("got %q, want the moved place named", got)
 	}
 }
 
 func TestHandler_HandleSendWeather(t *testing.T) {
-	repo := &mockUserRepo{user: &models.User{ID: 1}, city: "Moscow"}
-	weather := &mockWeatherProvider{
-		coord:   openweather.Coordinate{Lat: 55, Lon: 37},
-		weather: openweather.Weather{Temp: 10.5},
+	repo := &mockUserRepo{user: &models.User{ID: 1}, location: &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}}
+	provider := &mockWeatherProvider{
+		coord: weather.Coordinate{Lat: 55, Lon: 37},
+		weather: weather.Weather{
+			Temp:        10.5,
+			FeelsLike:   8.7,
+			Humidity:    71,
+			Pressure:    1013,
+			WindSpeed:   4.2,
+			WindDeg:     315,
+			WindGust:    9.1,
+			Clouds:      75,
+			Visibility:  10000,
+			Rain1h:      0.4,
+			Description: "небольшой дождь",
+			Sunrise:     time.Date(2025, 10, 1, 6, 32, 0, 0, time.UTC),
+			Sunset:      time.Date(2025, 10, 1, 18, 5, 0, 0, time.UTC),
+		},
 	}
 	bot := &mockBotAPI{}
 
-	h := New(bot, weather, repo)
+	h := New(bot, provider, repo)
 
 	update := tgbotapi.Update{
 		Message: &tgbotapi.Message{
@@ -107,38 +344,1295 @@ func TestHandler_HandleSendWeather(t *testing.T) {
 	if len(bot.sent) != 1 {
 		t.Errorf("got %d messages sent, want 1", len(bot.sent))
 	}
+	if provider.coordCalls != 0 {
+		t.Errorf("got %d Coordinates calls, want stored coordinates to be used", provider.coordCalls)
+	}
 	msg := bot.sent[0].(tgbotapi.MessageConfig)
-	if msg.Text != "Температура в вашем городе \nMoscow: 11°C" {
+	want := "Погода в вашем городе \nMoscow: 11°C, небольшой дождь" +
+		"\nОщущается как: 9°C" +
+		"\nВлажность: 71%" +
+		"\nДавление: 760 мм рт. ст." +
+		"\nВетер: 4 м/с, СЗ, порывы до 9 м/с" +
+		"\nОблачность: 75%" +
+		"\nВидимость: 10.0 км" +
+		"\nДождь: 0.4 мм/ч" +
+		"\nВосход: 06:32, закат: 18:05"
+	if msg.Text != want {
 		t.Errorf("unexpected message text: %v", msg.Text)
 	}
 }
 
-func TestHandler_HandleUpdate_EmptyCity(t *testing.T) {
+func TestHandler_HandleSendWeather_ProviderErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"Invalid key", &weather.StatusError{StatusCode: http.StatusUnauthorized}, "Сервис погоды отклонил запрос бота. Мы уже разбираемся, попробуйте позже"},
+		{"Rate limited", &weather.StatusError{StatusCode: http.StatusTooManyRequests, RetryAfter: 90 * time.Second}, "Сервис погоды перегружен запросами, попробуйте через 2 мин."},
+		{"Upstream down", fmt.Errorf("error get weather: %w", &weather.StatusError{StatusCode: http.StatusBadGateway}), "Сервис погоды сейчас не отвечает, попробуйте позже"},
+		{"Other error", errors.New("error unmarshal response"), "Не смогли получить погоду в этой местности"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockUserRepo{user: &models.User{ID: 1}, location: &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}}
+			bot := &mockBotAPI{}
+			h := New(bot, &mockWeatherProvider{err: tt.err}, repo)
+
+			h.handleSendWeather(context.Background(), tgbotapi.Update{
+				Message: &tgbotapi.Message{
+					From: &tgbotapi.User{ID: 1},
+					Chat: &tgbotapi.Chat{ID: 1},
+					Text: "/weather",
+				},
+			})
+
+			if len(bot.sent) != 1 {
+				t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+			}
+			if msg := bot.sent[0].(tgbotapi.MessageConfig); msg.Text != tt.want {
+				t.Errorf("got reply %q, want %q", msg.Text, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_CityDialog(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, OnboardedAt: time.Now()}}
+	provider := &mockWeatherProvider{coord: weather.Coordinate{Name: "Moscow", Lat: 55, Lon: 37}}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		t.Helper()
+		var entities []tgbotapi.MessageEntity
+		if command, _, _ := strings.Cut(text, " "); strings.HasPrefix(command, "/") {
+			entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+		}
+		bot.sent = nil
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: entities,
+			},
+		})
+		if len(bot.sent) != 1 {
+			t.Fatalf("%s: got %d messages sent, want 1", text, len(bot.sent))
+		}
+		return bot.sent[0].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("hello"); got != "Воспользуйтесь доступными командами" {
+		t.Errorf("got %q, want text without a question not understood", got)
+	}
+
+	if got := send("/city  "); got != "Какой город сохранить? Напишите название или /cancel для отмены" {
+		t.Errorf("got %q, want the city asked", got)
+	}
+	if repo.dialog == nil || repo.dialog.State != dialogCity {
+		t.Fatalf("got dialog %+v, want the city question stored", repo.dialog)
+	}
+
+	provider.err = weather.ErrCityNotFound
+	if got := send("Mosow"); got != "Город 'Mosow' не найден. Пожалуйста, проверьте правильность написания." {
+		t.Errorf("got %q, want the city not found", got)
+	}
+	if repo.location != nil || repo.dialog == nil {
+		t.Fatalf("got location %+v and dialog %+v, want the question still open", repo.location, repo.dialog)
+	}
+
+	provider.err = nil
+	if got := send("Moscow"); got != "Город Moscow успешно сохранен" {
+		t.Errorf("got %q, want the city saved", got)
+	}
+	if repo.location == nil || repo.location.City != "Moscow" || repo.dialog != nil {
+		t.Errorf("got location %+v and dialog %+v, want Moscow saved and the question answered", repo.location, repo.dialog)
+	}
+
+	send("/city")
+	if got := send("/cancel"); got != "Хорошо, отменено" {
+		t.Errorf("got %q, want the question cancelled", got)
+	}
+	if got := send("/cancel"); got != "Бот ничего не спрашивал" {
+		t.Errorf("got %q, want nothing to cancel", got)
+	}
+
+	send("/city")
+	repo.dialog.ExpiresAt = time.Now().Add(-time.Second)
+	if got := send("Paris"); got != "Воспользуйтесь доступными командами" {
+		t.Errorf("got %q, want the expired question ignored", got)
+	}
+}
+
+func TestHandler_HandleSendForecast(t *testing.T) {
+	loc := time.FixedZone("", 3*60*60)
+	repo := &mockUserRepo{user: &models.User{ID: 1}, location: &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}}
+	provider := &mockWeatherProvider{
+		coord: weather.Coordinate{Lat: 55, Lon: 37},
+		forecast: weather.Forecast{
+			Days: []weather.DailyForecast{
+				{Date: time.Date(2025, 12, 15, 0, 0, 0, 0, loc), TempMin: -3.4, TempMax: 1.6, Description: "снег"},
+				{Date: time.Date(2025, 12, 16, 0, 0, 0, 0, loc), TempMin: -5, TempMax: -1, Description: "облачно"},
+				{Date: time.Date(2025, 12, 17, 0, 0, 0, 0, loc), TempMin: -7, TempMax: -2, Description: "ясно"},
+			},
+		},
+	}
+
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"Two days", "/forecast 2", "Прогноз погоды \nMoscow:\nПн 15.12: -3…2°C, снег\nВт 16.12: -5…-1°C, облачно"},
+		{"Invalid days", "/forecast 9", "Укажите количество дней от 1 до 5: /forecast [дни]"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bot := &mockBotAPI{}
+			h := New(bot, provider, repo)
+
+			update := tgbotapi.Update{
+				Message: &tgbotapi.Message{
+					From:     &tgbotapi.User{ID: 1},
+					Chat:     &tgbotapi.Chat{ID: 1},
+					Text:     tt.text,
+					Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 9}},
+				},
+			}
+
+			h.handleUpdate(context.Background(), update)
+
+			if len(bot.sent) != 1 {
+				t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+			}
+			msg := bot.sent[0].(tgbotapi.MessageConfig)
+			if msg.Text != tt.want {
+				t.Errorf("unexpected message text: %v", msg.Text)
+			}
+		})
+	}
+}
+
+func TestHandler_HandleSubscribe(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1}, location: &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}}
+	provider := &mockWeatherProvider{
+		coord:   weather.Coordinate{Lat: 55, Lon: 37},
+		weather: weather.Weather{Timezone: 3 * 60 * 60},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	update := tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 42},
+			Text:     "/subscribe 07:30",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 10}},
+		},
+	}
+
+	h.handleUpdate(context.Background(), update)
+
+	if repo.sub == nil {
+		t.Fatal("subscription was not saved")
+	}
+	if repo.sub.ChatID != 42 || repo.sub.NotifyAt != 7*60+30 || repo.sub.TZOffset != 3*60*60 {
+		t.Errorf("unexpected subscription: %+v", repo.sub)
+	}
+	local := repo.sub.NextRunAt.In(time.FixedZone("", 3*60*60))
+	if local.Hour() != 7 || local.Minute() != 30 || !repo.sub.NextRunAt.After(time.Now()) {
+		t.Errorf("unexpected next run: %v", repo.sub.NextRunAt)
+	}
+	msg := bot.sent[0].(tgbotapi.MessageConfig)
+	if msg.Text != "Ежедневная сводка будет приходить в 07:30 по местному времени города Moscow" {
+		t.Errorf("unexpected message text: %v", msg.Text)
+	}
+}
+
+func TestHandler_HandleLocations(t *testing.T) {
 	repo := &mockUserRepo{user: &models.User{ID: 1}}
-	weather := &mockWeatherProvider{}
+	provider := &mockWeatherProvider{
+		coord:   weather.Coordinate{Name: "Kazan", Lat: 55.79, Lon: 49.12},
+		weather: weather.Weather{Temp: 3, Description: "ясно"},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		command, _, _ := strings.Cut(text, " ")
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("/addcity Office kazan"); got != "Место office (Kazan) успешно сохранено" {
+		t.Errorf("unexpected /addcity reply: %v", got)
+	}
+	repo.locations = append(repo.locations, models.Location{Name: "dacha", City: "Istra"})
+
+	if got := send("/default dacha"); got != "Место dacha выбрано по умолчанию" {
+		t.Errorf("unexpected /default reply: %v", got)
+	}
+	if got := send("/cities"); got != "Ваши места:\noffice - Kazan\ndacha - Istra (по умолчанию)" {
+		t.Errorf("unexpected /cities reply: %v", got)
+	}
+	if got := send("/weather OFFICE"); !strings.HasPrefix(got, "Погода в вашем городе \nKazan: 3°C, ясно") {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+	if got := send("/weather gym"); got != "Место 'gym' не найдено. Список сохраненных мест - /cities" {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+	if got := send("/delcity office"); got != "Место office удалено" {
+		t.Errorf("unexpected /delcity reply: %v", got)
+	}
+	if len(repo.locations) != 1 || repo.locations[0].Name != "dacha" {
+		t.Errorf("unexpected locations left: %+v", repo.locations)
+	}
+}
+
+func TestHandler_HandleSendWeather_LegacyLocation(t *testing.T) {
+	repo := &mockUserRepo{
+		user:      &models.User{ID: 1},
+		locations: []models.Location{{UserID: 1, Name: "home", City: "Moscow", IsDefault: true}},
+	}
+	repo.location = &repo.locations[0]
+	provider := &mockWeatherProvider{
+		coord:   weather.Coordinate{Name: "Moscow", Country: "RU", Lat: 55.75, Lon: 37.62},
+		weather: weather.Weather{Temp: 1},
+	}
 	bot := &mockBotAPI{}
 
-	h := New(bot, weather, repo)
+	h := New(bot, provider, repo)
 
 	update := tgbotapi.Update{
 		Message: &tgbotapi.Message{
 			From:     &tgbotapi.User{ID: 1},
 			Chat:     &tgbotapi.Chat{ID: 1},
-			Text:     "/city  ", // Empty arguments
+			Text:     "/weather",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 8}},
+		},
+	}
+
+	h.handleUpdate(context.Background(), update)
+
+	if provider.coordCalls != 1 {
+		t.Errorf("got %d Coordinates calls, want 1", provider.coordCalls)
+	}
+	got := repo.locations[0]
+	if !got.Geocoded || got.Lat != 55.75 || got.Country != "RU" || !got.IsDefault {
+		t.Errorf("legacy location was not backfilled: %+v", got)
+	}
+}
+
+func TestHandler_HandleSetCity_Ambiguous(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, OnboardedAt: time.Now()}}
+	provider := &mockWeatherProvider{
+		cities: []weather.Coordinate{
+			{Name: "Springfield", State: "Illinois", Country: "US", Lat: 39.8, Lon: -89.64},
+			{Name: "Springfield", State: "Missouri", Country: "US", Lat: 37.21, Lon: -93.3},
+		},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	h.handleUpdate(context.Background(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/city Springfield",
 			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
 		},
+	})
+
+	if repo.location != nil {
+		t.Fatalf("city should not be saved before it is chosen, got %+v", repo.location)
+	}
+	if len(bot.sent) != 1 {
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+	}
+	keyboard, ok := bot.sent[0].(tgbotapi.MessageConfig).ReplyMarkup.(tgbotapi.InlineKeyboardMarkup)
+	if !ok || len(keyboard.InlineKeyboard) != 2 {
+		t.Fatalf("expected inline keyboard with 2 cities, got %#v", bot.sent[0].(tgbotapi.MessageConfig).ReplyMarkup)
+	}
+	button := keyboard.InlineKeyboard[1][0]
+	if button.Text != "Springfield, Missouri, US" {
+		t.Errorf("unexpected button text: %v", button.Text)
+	}
+
+	// The choice is stored with the repo, so any replica can handle the tap.
+	replica := New(bot, provider, repo)
+
+	callback := func(userID int64) {
+		replica.handleUpdate(context.Background(), tgbotapi.Update{
+			CallbackQuery: &tgbotapi.CallbackQuery{
+				ID:      "cb",
+				From:    &tgbotapi.User{ID: userID},
+				Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+				Data:    *button.CallbackData,
+			},
+		})
+	}
+
+	callback(2)
+	if repo.location != nil {
+		t.Fatalf("city chosen by another user should be ignored, got %+v", repo.location)
+	}
+
+	callback(1)
+	if repo.location == nil || repo.location.State != "Missouri" || repo.location.Lat != 37.21 {
+		t.Fatalf("got location %+v, want Springfield, Missouri", repo.location)
+	}
+	edit, ok := bot.sent[len(bot.sent)-2].(tgbotapi.EditMessageTextConfig)
+	if !ok || edit.Text != "Springfield, Missouri, US" {
+		t.Errorf("unexpected choice: %#v", bot.sent[len(bot.sent)-2])
+	}
+	confirmation, ok := bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig)
+	if !ok || confirmation.Text != "Город Springfield успешно сохранен" {
+		t.Fatalf("unexpected confirmation: %#v", bot.sent[len(bot.sent)-1])
+	}
+	if _, ok := confirmation.ReplyMarkup.(tgbotapi.ReplyKeyboardRemove); !ok {
+		t.Errorf("got markup %#v, want the /start keyboard removed", confirmation.ReplyMarkup)
+	}
+	if len(bot.requests) != 2 {
+		t.Errorf("got %d callback answers, want 2", len(bot.requests))
+	}
+
+	callback(1)
+	if answer := bot.requests[len(bot.requests)-1].(tgbotapi.CallbackConfig); answer.Text != "Выбор устарел, повторите команду" {
+		t.Errorf("repeated choice should be rejected, got %q", answer.Text)
+	}
+
+	// Buttons whose choice was not stored are replaced with the error.
+	repo.choiceErr = errors.New("db is down")
+	bot.sent = nil
+	h.handleUpdate(context.Background(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/city Springfield",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+		},
+	})
+	if len(bot.sent) != 2 {
+		t.Fatalf("got %d messages sent, want the choice and its rollback", len(bot.sent))
+	}
+	rollback, ok := bot.sent[1].(tgbotapi.EditMessageTextConfig)
+	if !ok || rollback.MessageID != 1 || rollback.Text != "Произошла ошибка" || rollback.ReplyMarkup != nil {
+		t.Errorf("unexpected rollback: %#v", bot.sent[1])
+	}
+}
+
+func TestHandler_HandleUpdate_SharedLocation(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, OnboardedAt: time.Now()}}
+	provider := &mockWeatherProvider{
+		coord:   weather.Coordinate{Name: "Moscow", Country: "RU", Lat: 55.75, Lon: 37.61},
+		weather: weather.Weather{Temp: -2, Description: "снег"},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	update := tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Location: &tgbotapi.Location{Latitude: 55.7039, Longitude: 37.5287},
+		},
 	}
 
 	h.handleUpdate(context.Background(), update)
 
-	if repo.city != "" {
-		t.Errorf("repo city should be empty, got %v", repo.city)
+	if repo.location == nil || repo.location.City != "Moscow" {
+		t.Fatalf("got location %+v, want Moscow", repo.location)
+	}
+	if repo.location.Lat != 55.7039 || repo.location.Lon != 37.5287 {
+		t.Errorf("shared coordinates should be saved, got %v, %v", repo.location.Lat, repo.location.Lon)
 	}
 	if len(bot.sent) != 1 {
-		t.Errorf("got %d messages sent, want 1", len(bot.sent))
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
 	}
 	msg := bot.sent[0].(tgbotapi.MessageConfig)
-	if msg.Text != "Пожалуйста, укажите город: /city <название>" {
+	if !strings.HasPrefix(msg.Text, "Город Moscow успешно сохранен\n\nПогода в вашем городе \nMoscow: -2°C, снег") {
 		t.Errorf("unexpected message text: %v", msg.Text)
 	}
+
+	// Providers without reverse geocoding name the place by its coordinates.
+	provider.reverseErr = weather.ErrNotSupported
+	h.handleUpdate(context.Background(), update)
+	if repo.location.City != "55.70, 37.53" || repo.location.Lat != 55.7039 {
+		t.Errorf("got location %+v, want one named by coordinates", repo.location)
+	}
+}
+
+func TestHandler_HandleLang(t *testing.T) {
+	repo := &mockUserRepo{}
+	provider := &mockWeatherProvider{
+		weather: weather.Weather{Temp: 5, Description: "light rain"},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		command, _, _ := strings.Cut(text, " ")
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1, LanguageCode: "en-US"},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("/weather"); got != "Save your city first - /city <your city>" {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+	if repo.user == nil || repo.user.Language != "en" {
+		t.Fatalf("user should be created with the client language, got %+v", repo.user)
+	}
+	if got := send("/lang"); got != "Current language: English. Change it: /lang <ru|en>" {
+		t.Errorf("unexpected /lang reply: %v", got)
+	}
+	if got := send("/lang de"); got != "Available languages: ru, en" {
+		t.Errorf("unexpected /lang reply: %v", got)
+	}
+
+	repo.location = &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}
+	if got := send("/weather"); !strings.HasPrefix(got, "Weather in your city \nMoscow: 5°C, light rain") {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+	if provider.params.Lang != "en" {
+		t.Errorf("got weather requested in %q, want en", provider.params.Lang)
+	}
+
+	if got := send("/lang RU"); got != "Язык изменен на русский" {
+		t.Errorf("unexpected /lang reply: %v", got)
+	}
+	if repo.user.Language != "ru" {
+		t.Errorf("got language %q saved, want ru", repo.user.Language)
+	}
+	send("/weather")
+	if provider.params.Lang != "ru" {
+		t.Errorf("got weather requested in %q, want ru", provider.params.Lang)
+	}
+}
+
+func TestHandler_HandleUnits(t *testing.T) {
+	repo := &mockUserRepo{
+		user:     &models.User{ID: 1, Language: "en"},
+		location: &models.Location{City: "New York", Lat: 40.71, Lon: -74.01, Geocoded: true},
+	}
+	provider := &mockWeatherProvider{
+		weather: weather.Weather{
+			Temp:        50.4,
+			FeelsLike:   47.6,
+			Humidity:    60,
+			Pressure:    1013,
+			WindSpeed:   8.3,
+			WindDeg:     180,
+			Clouds:      20,
+			Visibility:  16093,
+			Description: "few clouds",
+			Sunrise:     time.Date(2025, 10, 1, 6, 55, 0, 0, time.UTC),
+			Sunset:      time.Date(2025, 10, 1, 18, 40, 0, 0, time.UTC),
+		},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		command, _, _ := strings.Cut(text, " ")
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("/units"); got != "Units: metric (°C, m/s, hPa). Change them: /units <metric|imperial|kelvin>" {
+		t.Errorf("unexpected /units reply: %v", got)
+	}
+	if got := send("/units celsius"); got != "Available units: metric, imperial, kelvin" {
+		t.Errorf("unexpected /units reply: %v", got)
+	}
+	if got := send("/units Imperial"); got != "Units set to imperial (°F, mph, inHg)" {
+		t.Errorf("unexpected /units reply: %v", got)
+	}
+	if repo.user.Units != "imperial" {
+		t.Errorf("got units %q saved, want imperial", repo.user.Units)
+	}
+
+	got := send("/weather")
+	if provider.params.Units != weather.Imperial {
+		t.Errorf("got weather requested in %q, want imperial", provider.params.Units)
+	}
+	want := "Weather in your city \nNew York: 50°F, few clouds" +
+		"\nFeels like: 48°F" +
+		"\nHumidity: 60%" +
+		"\nPressure: 29.91 inHg" +
+		"\nWind: 8 mph, S" +
+		"\nCloudiness: 20%" +
+		"\nVisibility: 10.0 mi" +
+		"\nSunrise: 06:55, sunset: 18:40"
+	if got != want {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+
+	send("/units kelvin")
+	provider.weather.Temp = 283.4
+	if got := send("/weather"); !strings.HasPrefix(got, "Weather in your city \nNew York: 283 K") || !strings.Contains(got, "Pressure: 1013 hPa") {
+		t.Errorf("unexpected /weather reply: %v", got)
+	}
+}
+
+func TestHandler_HandleAlerts(t *testing.T) {
+	repo := &mockUserRepo{
+		user:      &models.User{ID: 1, Units: "imperial"},
+		locations: []models.Location{{ID: 7, UserID: 1, Name: "home", City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true, IsDefault: true}},
+	}
+	repo.location = &repo.locations[0]
+	provider := &mockWeatherProvider{}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		command, _, _ := strings.Cut(text, " ")
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 42},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("/alert cold −4"); got != "Оповещение #1 для места home сохранено: минимальная температура завтра ниже -4°F" {
+		t.Errorf("unexpected /alert reply: %v", got)
+	}
+	if rule := repo.rules[0]; rule.ChatID != 42 || rule.Location.ID != 7 || math.Abs(rule.Threshold+20) > 1e-9 {
+		t.Errorf("got rule %+v, want threshold stored in °C", rule)
+	}
+	if got := send("/alert rain 160"); got != "Вероятность осадков указывается в процентах от 0 до 100" {
+		t.Errorf("unexpected /alert reply: %v", got)
+	}
+	if got := send("/alert rain 60,5 gym"); got != "Место 'gym' не найдено. Список сохраненных мест - /cities" {
+		t.Errorf("unexpected /alert reply: %v", got)
+	}
+	if got := send("/alert fog 1"); !strings.HasPrefix(got, "Укажите условие и порог") {
+		t.Errorf("unexpected /alert reply: %v", got)
+	}
+	send("/alert wind 30 HOME")
+	if got := send("/alerts"); got != "Ваши оповещения:"+
+		"\n#1 home: минимальная температура завтра ниже -4°F"+
+		"\n#2 home: порывы ветра в ближайшие сутки сильнее 30 миль/ч" {
+		t.Errorf("unexpected /alerts reply: %v", got)
+	}
+	if got := send("/delalert 3"); got != "Оповещение #3 не найдено. Список оповещений - /alerts" {
+		t.Errorf("unexpected /delalert reply: %v", got)
+	}
+	if got := send("/delalert #1"); got != "Оповещение #1 удалено" {
+		t.Errorf("unexpected /delalert reply: %v", got)
+	}
+
+	text, err := h.AlertMessage(context.Background(), repo.rules[0], alerts.Event{
+		Time:  time.Date(2025, 12, 15, 15, 0, 0, 0, time.UTC),
+		Value: 17.9,
+	})
+	if err != nil || text != "Оповещение #2: в Moscow в 15:00 порывы ветра до 40 миль/ч" {
+		t.Errorf("unexpected alert message %q, error %v", text, err)
+	}
+}
+
+func TestHandler_HandleWarnings(t *testing.T) {
+	loc := time.FixedZone("", 3*60*60)
+	repo := &mockUserRepo{user: &models.User{ID: 1}}
+	provider := &mockWeatherProvider{}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	send := func(text string) string {
+		command, _, _ := strings.Cut(text, " ")
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig).Text
+	}
+
+	if got := send("/warnings on"); got != "Сначала сохраните ваш город - /city <your city>" {
+		t.Errorf("unexpected /warnings reply: %v", got)
+	}
+
+	repo.location = &models.Location{City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true}
+	if got := send("/warnings"); got != "Для Moscow нет действующих предупреждений" {
+		t.Errorf("unexpected /warnings reply: %v", got)
+	}
+
+	provider.alerts = []weather.Alert{{
+		Sender:      "Гидрометцентр России",
+		Event:       "Сильный ветер",
+		Start:       time.Date(2025, 12, 15, 13, 0, 0, 0, loc),
+		End:         time.Date(2025, 12, 16, 1, 0, 0, 0, loc),
+		Description: "Порывы ветра 15-20 м/с\n",
+	}}
+	want := "Сильный ветер\n15.12 13:00 - 16.12 01:00\nИсточник: Гидрометцентр России\nПорывы ветра 15-20 м/с"
+	if got := send("/warnings"); got != "Предупреждения для Moscow:\n\n"+want {
+		t.Errorf("unexpected /warnings reply: %v", got)
+	}
+
+	if got := send("/warnings on"); !strings.HasPrefix(got, "Новые предупреждения для места по умолчанию (Moscow)") || !repo.warnings {
+		t.Errorf("unexpected /warnings on reply: %v", got)
+	}
+	if got := send("/warnings off"); got != "Уведомления о предупреждениях отключены" {
+		t.Errorf("unexpected /warnings off reply: %v", got)
+	}
+	if got := send("/warnings off"); got != "Уведомления о предупреждениях не включены. Включить - /warnings on" {
+		t.Errorf("unexpected /warnings off reply: %v", got)
+	}
+
+	text, err := h.WarningMessage(context.Background(), 1, *repo.location, provider.alerts[0])
+	if err != nil || text != "Новое предупреждение для Moscow:\n\n"+want {
+		t.Errorf("unexpected warning message %q, error %v", text, err)
+	}
+}
+
+func TestHandler_HandleAir(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1}, location: &models.Location{City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true}}
+	now := time.Now()
+	provider := &mockWeatherProvider{
+		air: weather.AirPollution{
+			Current: weather.AirQuality{AQI: 2, PM25: 12.4, PM10: 18.7, O3: 55.1, NO2: 14.9, SO2: 4.2, CO: 270.37},
+			Forecast: []weather.AirQuality{
+				{Time: now.Add(-time.Hour), AQI: 5},
+				{Time: now.Add(3 * time.Hour), AQI: 4},
+				{Time: now.Add(6 * time.Hour), AQI: 1},
+				{Time: now.Add(30 * time.Hour), AQI: 5},
+			},
+		},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	h.handleUpdate(context.Background(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/air",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 4}},
+		},
+	})
+
+	if provider.coordCalls != 0 {
+		t.Errorf("got %d Coordinates calls, want stored coordinates to be used", provider.coordCalls)
+	}
+	if len(bot.sent) != 1 {
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+	}
+	want := "Качество воздуха в Moscow: удовлетворительное (AQI 2)" +
+		"\nPM2.5: 12.4 мкг/м³ - удовлетворительное" +
+		"\nPM10: 18.7 мкг/м³ - хорошее" +
+		"\nO3: 55.1 мкг/м³ - хорошее" +
+		"\nNO2: 14.9 мкг/м³ - хорошее" +
+		"\nSO2: 4.2 мкг/м³ - хорошее" +
+		"\nCO: 270.4 мкг/м³ - хорошее" +
+		"\nХудшее в ближайшие сутки: плохое (AQI 4)"
+	if got := bot.sent[0].(tgbotapi.MessageConfig).Text; got != want {
+		t.Errorf("unexpected message text: %v", got)
+	}
+}
+
+func TestHandler_HandleRain(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en"}, location: &models.Location{City: "Moscow", Lat: 55.75, Lon: 37.62, Geocoded: true}}
+	provider := &mockWeatherProvider{err: weather.ErrNotSupported}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo)
+
+	h.handleUpdate(context.Background(), tgbotapi.Update{
+		Message: &tgbotapi.Message{
+			From:     &tgbotapi.User{ID: 1},
+			Chat:     &tgbotapi.Chat{ID: 1},
+			Text:     "/rain",
+			Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+		},
+	})
+
+	if len(bot.sent) != 1 {
+		t.Fatalf("got %d messages sent, want 1", len(bot.sent))
+	}
+	if got := bot.sent[0].(tgbotapi.MessageConfig).Text; got != "The current weather source doesn't provide a minute by minute precipitation forecast" {
+		t.Errorf("unexpected message text: %v", got)
+	}
+}
+
+func TestPressure(t *testing.T) {
+	tests := []struct {
+		lang  i18n.Lang
+		units weather.Units
+		want  string
+	}{
+		{i18n.Russian, weather.Metric, "760 мм рт. ст."},
+		{i18n.English, weather.Metric, "1013 hPa"},
+		{i18n.Russian, weather.Standard, "1013 гПа"},
+		{i18n.English, weather.Imperial, "29.91 inHg"},
+	}
+
+	for _, tt := range tests {
+		if got := pressure(tt.lang, tt.units, 1013); got != tt.want {
+			t.Errorf("pressure(%s, %s) = %q, want %q", tt.lang, tt.units, got, tt.want)
+		}
+	}
+}
+
+func TestRainTimeline(t *testing.T) {
+	// India is 5:30 ahead of UTC, Nepal 5:45.
+	for _, offset := range []int{5*60*60 + 30*60, 5*60*60 + 45*60} {
+		loc := time.FixedZone("", offset)
+		now := time.Date(2025, 12, 15, 12, 7, 30, 0, loc)
+		nowcast := weather.Nowcast{
+			Timezone: offset,
+			Hours:    []weather.Precipitation{{Time: time.Date(2025, 12, 15, 12, 0, 0, 0, loc)}},
+		}
+
+		start, _ := rainTimeline(nowcast, now)
+		if got := start.Format("15:04"); got != "12:00" {
+			t.Errorf("UTC offset %v: got timeline starting at %s, want 12:00 local time", time.Duration(offset)*time.Second, got)
+		}
+	}
+}
+
+func TestFormatRain(t *testing.T) {
+	loc := time.FixedZone("", 3*60*60)
+	now := time.Date(2025, 12, 15, 12, 4, 0, 0, loc)
+	hours := []weather.Precipitation{
+		{Time: time.Date(2025, 12, 15, 12, 0, 0, 0, loc), Pop: 0.2},
+		{Time: time.Date(2025, 12, 15, 13, 0, 0, 0, loc), Intensity: 3, Pop: 0.75},
+		{Time: time.Date(2025, 12, 15, 14, 0, 0, 0, loc), Intensity: 3, Pop: 0.9},
+	}
+	minutes := func(intensity func(minute int) float64) []weather.Precipitation {
+		var m []weather.Precipitation
+		for i := range 60 {
+			t := now.Add(time.Duration(i) * time.Minute)
+			m = append(m, weather.Precipitation{Time: t, Intensity: intensity(i)})
+		}
+		return m
+	}
+
+	tests := []struct {
+		name    string
+		nowcast weather.Nowcast
+		want    string
+	}{
+		{
+			name:    "Starts from the hourly data",
+			nowcast: weather.Nowcast{Timezone: 3 * 60 * 60, Hours: hours},
+			want: "12:00 ······▆▆▆▆▆▆ 14:00" +
+				"\nPrecipitation starts at about 13:00" +
+				"\nChance of precipitation: 75%",
+		},
+		{
+			name: "Stops within the minutely data",
+			nowcast: weather.Nowcast{Timezone: 3 * 60 * 60, Hours: hours, Minutes: minutes(func(minute int) float64 {
+				if minute < 25 {
+					return 0.3
+				}
+				return 0
+			})},
+			want: "12:00 ▂▂▂····▆▆▆▆▆ 14:00" +
+				"\nPrecipitating now, stops at about 12:30" +
+				"\nChance of precipitation: 75%",
+		},
+		{
+			name: "Without hourly data",
+			nowcast: weather.Nowcast{Timezone: 3 * 60 * 60, Minutes: minutes(func(minute int) float64 {
+				return 10
+			})},
+			want: "12:00 ███████ 13:10" +
+				"\nPrecipitating now, won't stop before 13:10",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := "Precipitation in Moscow for the next 2 hours:\n" + tt.want + "\n· dry ▂ drizzle ▄ light ▆ moderate █ heavy"
+			if got := formatRain(i18n.English, "Moscow", tt.nowcast, now); got != want {
+				t.Errorf("got\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestUserLimiter(t *testing.T) {
+	l := newUserLimiter(6, 2)
+	now := time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC)
+	l.now = func() time.Time { return now }
+
+	type result struct{ allowed, warn bool }
+	allow := func(userID int64) result {
+		allowed, warn := l.allow(userID)
+		return result{allowed, warn}
+	}
+
+	// The burst is allowed, then one warning and silence.
+	for i, want := range []result{{true, false}, {true, false}, {false, true}, {false, false}} {
+		if got := allow(1); got != want {
+			t.Errorf("request %d: got %+v, want %+v", i+1, got, want)
+		}
+	}
+	if got := allow(2); got != (result{true, false}) {
+		t.Errorf("got %+v for another user, want a bucket of their own", got)
+	}
+
+	// A token is added every 10 seconds at 6 per minute.
+	now = now.Add(10 * time.Second)
+	if got := allow(1); got != (result{true, false}) {
+		t.Errorf("got %+v after a refill, want allowed", got)
+	}
+	if got := allow(1); got != (result{false, true}) {
+		t.Errorf("got %+v after the refill is spent, want a new warning", got)
+	}
+}
+
+func TestHandler_Serve_Limits(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en"}, location: &models.Location{City: "Moscow", Lat: 55, Lon: 37, Geocoded: true}}
+	provider := &mockWeatherProvider{weather: weather.Weather{Temp: 10}}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo, WithLimits(Limits{PerMinute: 1, Burst: 2, MaxInFlight: 1}))
+
+	updates := make(chan tgbotapi.Update, 4)
+	for range 4 {
+		updates <- tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1, LanguageCode: "en"},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     "/weather",
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 8}},
+			},
+		}
+	}
+	close(updates)
+
+	h.serve(context.Background(), updates)
+
+	if len(bot.sent) != 3 {
+		t.Fatalf("got %d messages sent, want 2 weather replies and a warning", len(bot.sent))
+	}
+	limited := 0
+	for _, msg := range bot.sent {
+		if msg.(tgbotapi.MessageConfig).Text == "Too many requests, please slow down and try again in a minute" {
+			limited++
+		}
+	}
+	if limited != 1 {
+		t.Errorf("got %d slow down replies, want 1", limited)
+	}
+}
+
+func TestHandler_Serve_Order(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en", OnboardedAt: time.Now()}}
+	bot := &mockBotAPI{jitter: time.Millisecond}
+
+	h := New(bot, &mockWeatherProvider{}, repo, WithLimits(Limits{MaxInFlight: 4}))
+
+	const chats, messages = 20, 25
+	updates := make(chan tgbotapi.Update)
+	go func() {
+		defer close(updates)
+		for id := 1; id <= messages; id++ {
+			for chat := int64(1); chat <= chats; chat++ {
+				updates <- tgbotapi.Update{
+					Message: &tgbotapi.Message{
+						MessageID: id,
+						From:      &tgbotapi.User{ID: chat},
+						Chat:      &tgbotapi.Chat{ID: chat},
+						Text:      "hello",
+					},
+				}
+			}
+		}
+	}()
+
+	h.serve(context.Background(), updates)
+
+	replies := make(map[int64][]int)
+	for _, c := range bot.sent {
+		msg := c.(tgbotapi.MessageConfig)
+		replies[msg.ChatID] = append(replies[msg.ChatID], msg.ReplyToMessageID)
+	}
+	for chat := int64(1); chat <= chats; chat++ {
+		got := replies[chat]
+		if len(got) != messages || !slices.IsSorted(got) {
+			t.Errorf("chat %d: got replies to %v, want %d in the order sent", chat, got, messages)
+		}
+	}
+}
+
+func TestHandler_Serve_Backpressure(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en", OnboardedAt: time.Now()}}
+	bot := &mockBotAPI{release: make(chan struct{})}
+
+	h := New(bot, &mockWeatherProvider{}, repo, WithLimits(Limits{MaxInFlight: 1}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan tgbotapi.Update)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		h.serve(ctx, updates)
+	}()
+
+	// The worker is stuck on the first update, the next ones fill its queue
+	// and the last one is held by serve.
+	read := 0
+	for read < 2*workerQueueSize {
+		select {
+		case updates <- tgbotapi.Update{Message: &tgbotapi.Message{
+			From: &tgbotapi.User{ID: 1},
+			Chat: &tgbotapi.Chat{ID: 1},
+			Text: "hello",
+		}}:
+			read++
+			continue
+		case <-time.After(50 * time.Millisecond):
+		}
+		break
+	}
+	if want := 1 + workerQueueSize + 1; read != want {
+		t.Errorf("got %d updates read while the worker is busy, want %d", read, want)
+	}
+
+	cancel()
+	close(bot.release)
+	<-done
+	if want := 1 + workerQueueSize; len(bot.sent) != want {
+		t.Errorf("got %d messages sent, want %d with the queued updates drained on shutdown", len(bot.sent), want)
+	}
+}
+
+func TestHandler_Router(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en"}}
+	bot := &mockBotAPI{}
+
+	h := New(bot, &mockWeatherProvider{}, repo,
+		WithAdmins(42),
+		WithStats("weather_cache", func() any { return map[string]int{"hits": 3} }),
+	)
+	h.router.register(command{name: "boom", handle: func(ctx context.Context, update tgbotapi.Update) {
+		panic("boom")
+	}})
+
+	send := func(userID int64, text string) string {
+		t.Helper()
+		command, _, _ := strings.Cut(text, " ")
+		bot.sent = nil
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: userID, LanguageCode: "en"},
+				Chat:     &tgbotapi.Chat{ID: userID},
+				Text:     text,
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}},
+			},
+		})
+		if len(bot.sent) != 1 {
+			t.Fatalf("%s: got %d messages sent, want 1", text, len(bot.sent))
+		}
+		return bot.sent[0].(tgbotapi.MessageConfig).Text
+	}
+
+	tests := []struct {
+		name   string
+		userID int64
+		text   string
+		want   string
+	}{
+		{"Unknown command", 1, "/teleport", "This command is not available"},
+		{"Missing required arguments", 1, "/addcity home", "Please specify a place name and a city: /addcity <name> <city>"},
+		{"Admin command from a user", 1, "/stats", "This command is not available"},
+		{"Admin command from an admin", 42, "/stats", "{\n  \"weather_cache\": {\n    \"hits\": 3\n  }\n}"},
+		{"Panic", 1, "/boom", "Something went wrong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := send(tt.userID, tt.text); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandler_PublishCommands(t *testing.T) {
+	bot := &mockBotAPI{}
+	h := New(bot, &mockWeatherProvider{}, &mockUserRepo{}, WithAdmins(42))
+
+	if err := h.PublishCommands(); err != nil {
+		t.Fatalf("PublishCommands() error = %v", err)
+	}
+	if len(bot.requests) != 1+len(i18n.Supported)+1 {
+		t.Fatalf("got %d setMyCommands requests, want default, per language and admin ones", len(bot.requests))
+	}
+
+	names := func(config tgbotapi.SetMyCommandsConfig) []string {
+		var names []string
+		for _, c := range config.Commands {
+			names = append(names, c.Command)
+		}
+		return names
+	}
+
+	english := bot.requests[1+slices.Index(i18n.Supported, i18n.English)].(tgbotapi.SetMyCommandsConfig)
+	if english.LanguageCode != "en" || !slices.Contains(english.Commands, tgbotapi.BotCommand{Command: "weather", Description: "Current weather"}) {
+		t.Errorf("unexpected English commands %+v", english)
+	}
+	if slices.Contains(names(english), "stats") {
+		t.Errorf("got admin command published to everyone: %v", names(english))
+	}
+
+	admin := bot.requests[len(bot.requests)-1].(tgbotapi.SetMyCommandsConfig)
+	if admin.Scope == nil || admin.Scope.ChatID != 42 || !slices.Contains(names(admin), "stats") {
+		t.Errorf("unexpected admin commands %+v", admin)
+	}
+}
+
+func TestHandler_Onboarding(t *testing.T) {
+	repo := &mockUserRepo{}
+	provider := &mockWeatherProvider{
+		coord:   weather.Coordinate{Name: "Moscow", Lat: 55, Lon: 37},
+		weather: weather.Weather{Timezone: 3 * 60 * 60},
+	}
+	bot := &mockBotAPI{}
+
+	h := New(bot, provider, repo, WithAdmins(42))
+
+	send := func(text string) tgbotapi.MessageConfig {
+		t.Helper()
+		var entities []tgbotapi.MessageEntity
+		if command, _, _ := strings.Cut(text, " "); strings.HasPrefix(command, "/") {
+			entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+		}
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: 1, LanguageCode: "ru"},
+				Chat:     &tgbotapi.Chat{ID: 1},
+				Text:     text,
+				Entities: entities,
+			},
+		})
+		return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig)
+	}
+	// tap presses the button of the message and returns the next question.
+	tap := func(msg tgbotapi.MessageConfig, label string) tgbotapi.MessageConfig {
+		t.Helper()
+		for _, row := range msg.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard {
+			for _, button := range row {
+				if button.Text != label {
+					continue
+				}
+				h.handleUpdate(context.Background(), tgbotapi.Update{
+					CallbackQuery: &tgbotapi.CallbackQuery{
+						ID:      "cb",
+						From:    &tgbotapi.User{ID: 1},
+						Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+						Data:    *button.CallbackData,
+					},
+				})
+				return bot.sent[len(bot.sent)-1].(tgbotapi.MessageConfig)
+			}
+		}
+		t.Fatalf("no button %q in %q", label, msg.Text)
+		return tgbotapi.MessageConfig{}
+	}
+
+	// Saving a city before /start doesn't start the questions.
+	if got := send("/city Moscow").Text; got != "Город Moscow успешно сохранен" {
+		t.Fatalf("got %q, want the city saved without the language question", got)
+	}
+	repo.location = nil
+
+	greeting := send("/start")
+	keyboard, ok := greeting.ReplyMarkup.(tgbotapi.ReplyKeyboardMarkup)
+	if greeting.Text != i18n.T(i18n.Russian, "start.greeting") || !ok || !keyboard.Keyboard[0][0].RequestLocation {
+		t.Fatalf("unexpected greeting %+v", greeting)
+	}
+
+	langQuestion := send("Moscow")
+	if repo.location == nil || repo.location.City != "Moscow" {
+		t.Fatalf("got location %+v, want the typed city saved", repo.location)
+	}
+	if langQuestion.Text != i18n.T(i18n.Russian, "start.lang") {
+		t.Fatalf("got %q, want the language question", langQuestion.Text)
+	}
+	saved := bot.sent[len(bot.sent)-2].(tgbotapi.MessageConfig)
+	if _, ok := saved.ReplyMarkup.(tgbotapi.ReplyKeyboardRemove); !ok {
+		t.Errorf("got markup %#v, want the location keyboard removed with the typed city", saved.ReplyMarkup)
+	}
+
+	// In a group chat another member can't answer the questions.
+	english := langQuestion.ReplyMarkup.(tgbotapi.InlineKeyboardMarkup).InlineKeyboard[0][1]
+	h.handleUpdate(context.Background(), tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:      "cb",
+			From:    &tgbotapi.User{ID: 2},
+			Message: &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}},
+			Data:    *english.CallbackData,
+		},
+	})
+	if answer := bot.requests[len(bot.requests)-1].(tgbotapi.CallbackConfig); answer.Text != i18n.T(i18n.Russian, "start.not_yours") {
+		t.Fatalf("got %q, want the tap of another user rejected", answer.Text)
+	}
+	if repo.user.Language == "en" {
+		t.Fatal("got the language changed by another user")
+	}
+
+	unitsQuestion := tap(langQuestion, "English")
+	if repo.user.Language != "en" || unitsQuestion.Text != "Which units should I show the weather in?" {
+		t.Fatalf("got language %q and %q, want English and the units question", repo.user.Language, unitsQuestion.Text)
+	}
+
+	digestQuestion := tap(unitsQuestion, i18n.T(i18n.English, "units.imperial"))
+	if repo.user.Units != "imperial" || !strings.HasPrefix(digestQuestion.Text, "Send you a weather digest") {
+		t.Fatalf("got units %q and %q, want imperial and the digest question", repo.user.Units, digestQuestion.Text)
+	}
+
+	done := tap(digestQuestion, "08:00")
+	if repo.sub == nil || repo.sub.NotifyAt != 8*60 || repo.sub.TZOffset != 3*60*60 {
+		t.Errorf("got subscription %+v, want 08:00 local time", repo.sub)
+	}
+	if repo.user.OnboardedAt.IsZero() {
+		t.Error("onboarding was not completed")
+	}
+	if !strings.HasPrefix(done.Text, "All set!") || !strings.Contains(done.Text, "/weather [place] - Current weather") {
+		t.Errorf("got %q, want the list of commands", done.Text)
+	}
+	if strings.Contains(done.Text, "/stats") {
+		t.Errorf("got admin commands listed to a user: %q", done.Text)
+	}
+
+	if got := send("/start").Text; !strings.HasPrefix(got, "Your settings are already saved") {
+		t.Errorf("got %q on second /start, want the commands", got)
+	}
+	if got := send("Paris").Text; got != "Please use the available commands" {
+		t.Errorf("got %q, want text ignored after onboarding", got)
+	}
+}
+
+func TestHandler_Help(t *testing.T) {
+	repo := &mockUserRepo{user: &models.User{ID: 1, Language: "en", OnboardedAt: time.Now()}}
+	bot := &mockBotAPI{}
+
+	h := New(bot, &mockWeatherProvider{}, repo, WithAdmins(42))
+
+	help := func(userID int64) string {
+		bot.sent = nil
+		h.handleUpdate(context.Background(), tgbotapi.Update{
+			Message: &tgbotapi.Message{
+				From:     &tgbotapi.User{ID: userID},
+				Chat:     &tgbotapi.Chat{ID: userID},
+				Text:     "/help",
+				Entities: []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: 5}},
+			},
+		})
+		return bot.sent[0].(tgbotapi.MessageConfig).Text
+	}
+
+	got := help(1)
+	lines := strings.Split(got, "\n")
+	if lines[0] != "Commands:" || len(lines) != len(h.router.commands) {
+		t.Fatalf("got %q, want a line for each public command", got)
+	}
+	for _, want := range []string{
+		"/help - List of commands",
+		"/addcity <name> <city> - Save a place",
+		"/units [metric|imperial|kelvin] - Units of measurement",
+	} {
+		if !slices.Contains(lines, want) {
+			t.Errorf("got %q, want line %q", got, want)
+		}
+	}
+	if strings.Contains(got, "/stats") {
+		t.Errorf("got admin commands listed to a user: %q", got)
+	}
+
+	if got := help(42); !strings.Contains(got, "/stats - ") {
+		t.Errorf("got %q, want admin commands listed to an admin", got)
+	}
+}
+
+func TestWebhookHandler(t *testing.T) {
+	updates := make(chan tgbotapi.Update, 1)
+	webhook := newWebhookHandler(context.Background(), "s3cret", updates)
+
+	tests := []struct {
+		name       string
+		method     string
+		secret     string
+		body       string
+		wantStatus int
+		wantUpdate bool
+	}{
+		{"Valid update", http.MethodPost, "s3cret", `{"update_id": 7, "message": {"text": "/weather"}}`, http.StatusOK, true},
+		{"Wrong secret", http.MethodPost, "guess", `{"update_id": 7}`, http.StatusUnauthorized, false},
+		{"Missing secret", http.MethodPost, "", `{"update_id": 7}`, http.StatusUnauthorized, false},
+		{"Wrong method", http.MethodGet, "s3cret", "", http.StatusMethodNotAllowed, false},
+		{"Malformed body", http.MethodPost, "s3cret", `{"update_id":`, http.StatusBadRequest, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/telegram/webhook", strings.NewReader(tt.body))
+			if tt.secret != "" {
+				req.Header.Set("X-Telegram-Bot-Api-Secret-Token", tt.secret)
+			}
+			rec := httptest.NewRecorder()
+
+			webhook.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", rec.Code, tt.wantStatus)
+			}
+			select {
+			case update := <-updates:
+				if !tt.wantUpdate {
+					t.Errorf("unexpected update passed on: %+v", update)
+				} else if update.UpdateID != 7 || update.Message.Text != "/weather" {
+					t.Errorf("unexpected update: %+v", update)
+				}
+			default:
+				if tt.wantUpdate {
+					t.Error("update was not passed on")
+				}
+			}
+		})
+	}
 }
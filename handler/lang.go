@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type langKey struct{}
+
+// withLang stores the language of the user the update came from.
+func withLang(ctx context.Context, lang i18n.Lang) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// langFrom returns the language of the user being served.
+func langFrom(ctx context.Context) i18n.Lang {
+	lang, ok := ctx.Value(langKey{}).(i18n.Lang)
+	if !ok {
+		return i18n.Default
+	}
+	return lang
+}
+
+// userLang returns the saved language of the user, users created before
+// languages were stored get the default one.
+func userLang(language string) i18n.Lang {
+	lang, ok := i18n.Parse(language)
+	if !ok {
+		return i18n.Default
+	}
+	return lang
+}
+
+func (h *Handler) handleLang(ctx context.Context, update tgbotapi.Update) {
+	codes := make([]string, 0, len(i18n.Supported))
+	for _, lang := range i18n.Supported {
+		codes = append(codes, string(lang))
+	}
+
+	arg := strings.TrimSpace(update.Message.CommandArguments())
+	if arg == "" {
+		lang := langFrom(ctx)
+		h.reply(ctx, update, "lang.current", i18n.T(lang, "lang.name"), strings.Join(codes, "|"))
+		return
+	}
+
+	lang, ok := i18n.Parse(arg)
+	if !ok {
+		h.reply(ctx, update, "lang.unknown", strings.Join(codes, ", "))
+		return
+	}
+
+	err := h.userRepo.UpdateUserLanguage(ctx, update.Message.From.ID, string(lang))
+	if err != nil {
+		log.Println("error userRepo.UpdateUserLanguage: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	h.reply(withLang(ctx, lang), update, "lang.set")
+}
@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"study/weatherbot/i18n"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	// sweepThreshold is the number of user buckets after which full ones
+	// are removed, a full bucket is the same as none.
+	sweepThreshold = 10000
+	// defaultWorkers handle updates when Limits.MaxInFlight is not set.
+	defaultWorkers = 16
+	// workerQueueSize is the number of updates waiting for a worker before
+	// reading further updates is held back.
+	workerQueueSize = 16
+	// drainTimeout bounds handling the queued updates on shutdown, Telegram
+	// has them acknowledged already so they would be lost otherwise.
+	drainTimeout = 10 * time.Second
+)
+
+// Limits protect the weather quota from users sending commands faster than
+// they can be answered.
+type Limits struct {
+	// PerMinute updates a user may send on average, Burst of them at once.
+	// Users are not limited when PerMinute is zero.
+	PerMinute int
+	Burst     int
+	// MaxInFlight is the number of workers handling updates at the same
+	// time, defaultWorkers when zero.
+	MaxInFlight int
+}
+
+func WithLimits(limits Limits) Option {
+	return func(h *Handler) {
+		if limits.PerMinute > 0 {
+			h.userLimiter = newUserLimiter(limits.PerMinute, max(1, limits.Burst))
+		}
+		if limits.MaxInFlight > 0 {
+			h.workers = limits.MaxInFlight
+		}
+	}
+}
+
+// userLimiter is a token bucket per user.
+type userLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+	now   func() time.Time
+
+	mu      sync.Mutex
+	buckets map[int64]*bucket
+}
+
+type bucket struct {
+	tokens    float64
+	updatedAt time.Time
+	warned    bool // the user was told to slow down since the last allowed update
+}
+
+func newUserLimiter(perMinute int, burst int) *userLimiter {
+	return &userLimiter{
+		rate:    float64(perMinute) / 60,
+		burst:   float64(burst),
+		now:     time.Now,
+		buckets: make(map[int64]*bucket),
+	}
+}
+
+// allow takes a token from the user's bucket. warn is set for the first
+// rejected update in a row, so a spamming user gets a single reply.
+func (l *userLimiter) allow(userID int64) (allowed bool, warn bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	b, ok := l.buckets[userID]
+	if !ok {
+		if len(l.buckets) >= sweepThreshold {
+			l.sweep(now)
+		}
+		b = &bucket{tokens: l.burst, updatedAt: now}
+		l.buckets[userID] = b
+	}
+
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate)
+	b.updatedAt = now
+
+	if b.tokens < 1 {
+		warn = !b.warned
+		b.warned = true
+		return false, warn
+	}
+	b.tokens--
+	b.warned = false
+	return true, false
+}
+
+func (l *userLimiter) sweep(now time.Time) {
+	for userID, b := range l.buckets {
+		if b.tokens+now.Sub(b.updatedAt).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, userID)
+		}
+	}
+}
+
+// limitUser drops updates over the user's limit, the first one in a row
+// gets a request to slow down in the language of the user's Telegram
+// client. The user isn't looked up to keep rejected updates cheap.
+func (h *Handler) limitUser(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		if h.userLimiter == nil {
+			next(ctx, update)
+			return
+		}
+
+		from := update.SentFrom()
+		allowed, warn := h.userLimiter.allow(from.ID)
+		switch {
+		case allowed:
+			next(ctx, update)
+		case warn:
+			h.respond(withLang(ctx, i18n.FromLanguageCode(from.LanguageCode)), update, "rate.limited")
+		}
+	}
+}
@@ -0,0 +1,314 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+	"time"
+	"unicode/utf8"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const maxLocationNameLength = 32
+
+func (h *Handler) handleAddLocation(ctx context.Context, update tgbotapi.Update) {
+	name, cityInput, _ := strings.Cut(strings.TrimSpace(update.Message.CommandArguments()), " ")
+	name = normalizeLocationName(name)
+	cityInput = strings.TrimSpace(cityInput)
+	if name == "" || cityInput == "" {
+		h.reply(ctx, update, "location.add_usage")
+		return
+	}
+
+	if utf8.RuneCountInString(name) > maxLocationNameLength {
+		h.reply(ctx, update, "location.name_too_long", maxLocationNameLength)
+		return
+	}
+
+	h.selectCity(ctx, update, cityInput, name)
+}
+
+func (h *Handler) handleListLocations(ctx context.Context, update tgbotapi.Update) {
+	locations, err := h.userRepo.ListLocations(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.ListLocations: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if len(locations) == 0 {
+		h.reply(ctx, update, "location.list_empty")
+		return
+	}
+
+	lang := langFrom(ctx)
+
+	var sb strings.Builder
+	sb.WriteString(i18n.T(lang, "location.list_header"))
+	for _, location := range locations {
+		sb.WriteString("\n")
+		sb.WriteString(i18n.T(lang, "location.list_item", location.Name, location.City))
+		if location.IsDefault {
+			sb.WriteString(i18n.T(lang, "location.default_mark"))
+		}
+	}
+
+	h.replyText(update, sb.String())
+}
+
+func (h *Handler) handleDeleteLocation(ctx context.Context, update tgbotapi.Update) {
+	name := normalizeLocationName(update.Message.CommandArguments())
+	if name == "" {
+		h.reply(ctx, update, "location.delete_usage")
+		return
+	}
+
+	deleted, err := h.userRepo.DeleteLocation(ctx, update.Message.From.ID, name)
+	if err != nil {
+		log.Println("error userRepo.DeleteLocation: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !deleted {
+		h.reply(ctx, update, "location.not_found", name)
+		return
+	}
+
+	h.reply(ctx, update, "location.deleted", name)
+}
+
+func (h *Handler) handleSetDefaultLocation(ctx context.Context, update tgbotapi.Update) {
+	name := normalizeLocationName(update.Message.CommandArguments())
+	if name == "" {
+		h.reply(ctx, update, "location.default_usage")
+		return
+	}
+
+	updated, err := h.userRepo.SetDefaultLocation(ctx, update.Message.From.ID, name)
+	if err != nil {
+		log.Println("error userRepo.SetDefaultLocation: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !updated {
+		h.reply(ctx, update, "location.not_found", name)
+		return
+	}
+
+	h.reply(ctx, update, "location.default_set", name)
+}
+
+// handleSharedLocation saves the place shared from the Telegram client as
+// the default location and answers with the weather there.
+func (h *Handler) handleSharedLocation(ctx context.Context, update tgbotapi.Update) {
+	shared := update.Message.Location
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	city, err := h.owProvider.ReverseGeocode(weatherCtx, shared.Latitude, shared.Longitude)
+	if errors.Is(err, weather.ErrNotSupported) {
+		// Without reverse geocoding the place is named by its coordinates.
+		city, err = weather.Coordinate{Name: fmt.Sprintf("%.2f, %.2f", shared.Latitude, shared.Longitude)}, nil
+	}
+	if err != nil {
+		if errors.Is(err, weather.ErrCityNotFound) {
+			h.reply(ctx, update, "city.reverse_not_found")
+			return
+		}
+		log.Println("error owProvider.ReverseGeocode: ", err)
+		h.replyProviderError(ctx, update, err, "city.reverse_failed")
+		return
+	}
+
+	// The shared point is more precise than the centre of the found city.
+	city.Lat, city.Lon = shared.Latitude, shared.Longitude
+
+	text, saved := h.saveLocation(ctx, update.Message.From.ID, "", city)
+
+	current, err := h.owProvider.Weather(weatherCtx, city.Lat, city.Lon, weatherParams(ctx))
+	if err != nil {
+		log.Println("error owProvider.Weather: ", err)
+	} else {
+		text += "\n\n" + formatWeather(langFrom(ctx), unitsFrom(ctx), city.Name, current)
+	}
+	h.replyRemovingKeyboard(update, text)
+
+	if saved {
+		h.cityQuestionAnswered(ctx, update.Message.Chat.ID, update.Message.From.ID)
+	}
+}
+
+// selectCity saves the city as the named location, or as the default one
+// when name is empty. When the city name is ambiguous the user is asked to
+// pick one of the matches and the location is saved from the callback.
+func (h *Handler) selectCity(ctx context.Context, update tgbotapi.Update, cityInput string, name string) {
+	cities, ok := h.findCities(ctx, update, cityInput)
+	if !ok {
+		return
+	}
+
+	if len(cities) == 1 {
+		text, saved := h.saveLocation(ctx, update.Message.From.ID, name, cities[0])
+		if name != "" {
+			h.replyText(update, text)
+			return
+		}
+		h.replyRemovingKeyboard(update, text)
+		if saved {
+			h.cityQuestionAnswered(ctx, update.Message.Chat.ID, update.Message.From.ID)
+		}
+		return
+	}
+
+	rows := make([][]tgbotapi.InlineKeyboardButton, 0, len(cities))
+	for i, city := range cities {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(cityLabel(city), fmt.Sprintf("%s%d", cityCallbackPrefix, i)),
+		))
+	}
+
+	msg := tgbotapi.NewMessage(update.Message.Chat.ID, i18n.T(langFrom(ctx), "city.choose"))
+	msg.ReplyToMessageID = update.Message.MessageID
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	sent, err := h.bot.Send(msg)
+	if err != nil {
+		log.Println("error bot.Send: ", err)
+		return
+	}
+
+	choice := models.CityChoice{
+		ChatID:       update.Message.Chat.ID,
+		MessageID:    sent.MessageID,
+		UserID:       update.Message.From.ID,
+		LocationName: name,
+		ExpiresAt:    time.Now().Add(cityChoiceTTL),
+	}
+	for _, city := range cities {
+		choice.Cities = append(choice.Cities, newLocation(choice.UserID, name, city))
+	}
+	err = h.userRepo.SaveCityChoice(ctx, choice)
+	if err != nil {
+		log.Println("error userRepo.SaveCityChoice: ", err)
+		// The buttons could only answer that the choice expired, so they
+		// are replaced with the error.
+		_, err = h.bot.Send(tgbotapi.NewEditMessageText(choice.ChatID, choice.MessageID, i18n.T(langFrom(ctx), "error.generic")))
+		if err != nil {
+			log.Println("error bot.Send: ", err)
+		}
+	}
+}
+
+// findCities validates the city input and returns the matching places. The
+// user is answered with the reason when it returns false.
+func (h *Handler) findCities(ctx context.Context, update tgbotapi.Update, cityInput string) ([]weather.Coordinate, bool) {
+	if len(cityInput) < 2 {
+		h.reply(ctx, update, "city.too_short")
+		return nil, false
+	}
+
+	// Validate city existence and get normalized name
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	cities, err := h.owProvider.Cities(weatherCtx, cityInput)
+	if err != nil {
+		if errors.Is(err, weather.ErrCityNotFound) {
+			h.reply(ctx, update, "city.not_found", cityInput)
+			return nil, false
+		}
+		log.Println("error owProvider.Cities: ", err)
+		h.replyProviderError(ctx, update, err, "city.lookup_failed")
+		return nil, false
+	}
+
+	return cities, true
+}
+
+// saveLocation stores the city as the named location, or as the default one
+// when name is empty, and returns the reply for the user and whether it was
+// saved.
+func (h *Handler) saveLocation(ctx context.Context, userID int64, name string, city weather.Coordinate) (string, bool) {
+	lang := langFrom(ctx)
+	if name == "" {
+		place, err := h.userRepo.UpdateUserCity(ctx, newLocation(userID, "", city))
+		if err != nil {
+			log.Println("error userRepo.updateUserCity: ", err)
+			return i18n.T(lang, "city.save_failed", city.Name), false
+		}
+		// The default place may be another one than home, e.g. office, the
+		// user is told which one moved.
+		if place != models.DefaultLocationName {
+			return i18n.T(lang, "city.saved_place", city.Name, place), true
+		}
+		return i18n.T(lang, "city.saved", city.Name), true
+	}
+
+	err := h.userRepo.AddLocation(ctx, newLocation(userID, name, city))
+	if err != nil {
+		log.Println("error userRepo.AddLocation: ", err)
+		return i18n.T(lang, "city.save_failed", city.Name), false
+	}
+	return i18n.T(lang, "location.saved", name, city.Name), true
+}
+
+// cityLabel describes a geocoding match well enough to tell apart cities
+// sharing a name.
+func cityLabel(city weather.Coordinate) string {
+	parts := []string{city.Name}
+	if city.State != "" {
+		parts = append(parts, city.State)
+	}
+	if city.Country != "" {
+		parts = append(parts, city.Country)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// locationCoordinates returns the coordinates stored with the location.
+// Locations saved before coordinates were stored are geocoded once and
+// updated in place.
+func (h *Handler) locationCoordinates(ctx context.Context, location *models.Location) (float64, float64, error) {
+	if location.Geocoded {
+		return location.Lat, location.Lon, nil
+	}
+
+	coord, err := h.owProvider.Coordinates(ctx, location.City)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error owProvider.Coordinates: %w", err)
+	}
+
+	err = h.userRepo.AddLocation(ctx, newLocation(location.UserID, location.Name, coord))
+	if err != nil {
+		log.Println("error userRepo.AddLocation: ", err)
+	}
+
+	return coord.Lat, coord.Lon, nil
+}
+
+// newLocation turns a geocoding result into a location to be saved.
+func newLocation(userID int64, name string, coord weather.Coordinate) models.Location {
+	return models.Location{
+		UserID:   userID,
+		Name:     name,
+		City:     coord.Name,
+		Country:  coord.Country,
+		State:    coord.State,
+		Lat:      coord.Lat,
+		Lon:      coord.Lon,
+		Geocoded: true,
+	}
+}
+
+// normalizeLocationName makes location names case-insensitive.
+func normalizeLocationName(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
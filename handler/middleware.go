@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"runtime/debug"
+	"study/weatherbot/i18n"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// recoverPanic keeps a bug in one handler from taking the bot down, the
+// user gets a generic error in the language of their Telegram client instead
+// of no answer.
+func (h *Handler) recoverPanic(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Printf("panic handling update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+				h.respond(withLang(ctx, i18n.FromLanguageCode(update.SentFrom().LanguageCode)), update, "error.generic")
+			}
+		}()
+		next(ctx, update)
+	}
+}
+
+// logUpdate logs who sent what and how long it took to handle.
+func (h *Handler) logUpdate(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		start := time.Now()
+		next(ctx, update)
+
+		switch {
+		case update.CallbackQuery != nil:
+			log.Printf("[%s] callback %s (%v)", update.CallbackQuery.From.UserName, update.CallbackQuery.Data, time.Since(start).Round(time.Millisecond))
+		case update.Message != nil:
+			log.Printf("[%s] %s (%v)", update.Message.From.UserName, update.Message.Text, time.Since(start).Round(time.Millisecond))
+		}
+	}
+}
+
+// loadUser puts the language and units of the user and whether they are
+// still answering the /start questions into ctx, new users are created first.
+func (h *Handler) loadUser(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		from := update.SentFrom()
+		user, err := h.ensureUser(ctx, from)
+		if err != nil {
+			log.Println("error h.ensureUser: ", err)
+			ctx = withLang(ctx, i18n.FromLanguageCode(from.LanguageCode))
+			h.respond(ctx, update, "error.generic")
+			return
+		}
+
+		ctx = withLang(ctx, userLang(user.Language))
+		ctx = withUnits(ctx, userUnits(user.Units))
+		ctx = withOnboarding(ctx, user.OnboardedAt.IsZero())
+		next(ctx, update)
+	}
+}
+
+// adminOnly answers users other than the admins as if the command didn't
+// exist.
+func (h *Handler) adminOnly(next handlerFunc) handlerFunc {
+	return func(ctx context.Context, update tgbotapi.Update) {
+		if !h.admins[update.SentFrom().ID] {
+			h.handleUnknownCommand(ctx, update)
+			return
+		}
+		next(ctx, update)
+	}
+}
+
+// respond answers a message or a callback query with the message key.
+func (h *Handler) respond(ctx context.Context, update tgbotapi.Update, key string) {
+	switch {
+	case update.CallbackQuery != nil:
+		h.answerCallback(ctx, update.CallbackQuery, key)
+	case update.Message != nil:
+		h.reply(ctx, update, key)
+	}
+}
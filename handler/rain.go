@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	rainWindow = 2 * time.Hour
+	rainSlot   = 10 * time.Minute
+)
+
+// rainLevels are the bars of the /rain timeline with the upper bounds of the
+// intensity they stand for in mm/h: dry, drizzle, light, moderate, heavy
+// for the rest.
+var rainLevels = []struct {
+	bar   string
+	limit float64
+}{
+	{"·", 0.1},
+	{"▂", 0.5},
+	{"▄", 2.5},
+	{"▆", 7.6},
+}
+
+const heavyRainBar = "█"
+
+func (h *Handler) handleRain(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.findLocation(ctx, update, normalizeLocationName(update.Message.CommandArguments()))
+	if !ok {
+		return
+	}
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
+	if err != nil {
+		h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+		return
+	}
+
+	nowcast, err := h.owProvider.Nowcast(weatherCtx, lat, lon)
+	if errors.Is(err, weather.ErrNotSupported) {
+		h.reply(ctx, update, "rain.not_supported")
+		return
+	}
+	if err != nil {
+		log.Println("error owProvider.Nowcast: ", err)
+		h.replyProviderError(ctx, update, err, "rain.failed")
+		return
+	}
+
+	h.replyText(update, formatRain(langFrom(ctx), location.City, nowcast, time.Now()))
+}
+
+// rainTimeline returns the intensity for each slot of the window starting at
+// the slot now falls in. Minutely data is preferred, slots beyond it take the
+// intensity of their hour. The timeline ends early when data runs out.
+//
+// Slots start at whole ten minutes of the location's local time, which is
+// not the case for UTC ones in zones with a :45 offset, e.g. Nepal.
+func rainTimeline(nowcast weather.Nowcast, now time.Time) (start time.Time, intensities []float64) {
+	local := now.In(time.FixedZone("", nowcast.Timezone))
+	sinceHour := time.Duration(local.Minute())*time.Minute + time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+	start = local.Add(-sinceHour % rainSlot)
+	for t := start; t.Before(start.Add(rainWindow)); t = t.Add(rainSlot) {
+		intensity, ok := slotIntensity(nowcast, t)
+		if !ok {
+			break
+		}
+		intensities = append(intensities, intensity)
+	}
+	return start, intensities
+}
+
+func slotIntensity(nowcast weather.Nowcast, t time.Time) (float64, bool) {
+	intensity, found := 0.0, false
+	for _, m := range nowcast.Minutes {
+		if !m.Time.Before(t) && m.Time.Before(t.Add(rainSlot)) {
+			intensity, found = max(intensity, m.Intensity), true
+		}
+	}
+	if found {
+		return intensity, true
+	}
+
+	for _, h := range nowcast.Hours {
+		if !t.Before(h.Time) && t.Before(h.Time.Add(time.Hour)) {
+			return h.Intensity, true
+		}
+	}
+	return 0, false
+}
+
+func rainBar(intensity float64) string {
+	for _, level := range rainLevels {
+		if intensity < level.limit {
+			return level.bar
+		}
+	}
+	return heavyRainBar
+}
+
+func isRaining(intensity float64) bool {
+	return intensity >= rainLevels[0].limit
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handlerFunc handles an update, see dispatchUpdate for what ctx carries.
+type handlerFunc func(ctx context.Context, update tgbotapi.Update)
+
+// middleware wraps a handler with behaviour shared by several of them.
+type middleware func(next handlerFunc) handlerFunc
+
+// chain wraps h with middleware, the first one runs first.
+func chain(h handlerFunc, middleware ...middleware) handlerFunc {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+	return h
+}
+
+// command is a bot command. Its description is the message key
+// "command.<name>".
+type command struct {
+	name string
+	// args is the argument schema shown to users, <required> and
+	// [optional] arguments separated by spaces.
+	args string
+	// usage is the message key replied when required arguments are missing.
+	usage      string
+	handle     handlerFunc
+	middleware []middleware
+	// hidden commands are not published to Telegram, e.g. admin ones.
+	hidden bool
+}
+
+// required returns the number of required arguments in the schema.
+func (c *command) required() int {
+	return strings.Count(c.args, "<")
+}
+
+// router dispatches commands to the handlers registered for them.
+type router struct {
+	commands []*command // in the order they are listed to users
+	byName   map[string]*command
+	unknown  handlerFunc
+	reply    func(ctx context.Context, update tgbotapi.Update, key string, args ...any)
+}
+
+func newRouter(unknown handlerFunc, reply func(ctx context.Context, update tgbotapi.Update, key string, args ...any)) *router {
+	return &router{
+		byName:  make(map[string]*command),
+		unknown: unknown,
+		reply:   reply,
+	}
+}
+
+func (r *router) register(c command) {
+	if _, ok := r.byName[c.name]; ok {
+		panic(fmt.Sprintf("handler: command /%s registered twice", c.name))
+	}
+	c.handle = chain(c.handle, c.middleware...)
+	r.commands = append(r.commands, &c)
+	r.byName[c.name] = &c
+}
+
+func (r *router) route(ctx context.Context, update tgbotapi.Update) {
+	c, ok := r.byName[update.Message.Command()]
+	if !ok {
+		r.unknown(ctx, update)
+		return
+	}
+
+	if args := strings.Fields(update.Message.CommandArguments()); len(args) < c.required() && c.usage != "" {
+		r.reply(ctx, update, c.usage)
+		return
+	}
+	c.handle(ctx, update)
+}
+
+// botCommands returns the published commands with descriptions in lang.
+func (r *router) botCommands(lang i18n.Lang) []tgbotapi.BotCommand {
+	var commands []tgbotapi.BotCommand
+	for _, c := range r.commands {
+		if c.hidden {
+			continue
+		}
+		commands = append(commands, tgbotapi.BotCommand{
+			Command:     c.name,
+			Description: i18n.T(lang, "command."+c.name),
+		})
+	}
+	return commands
+}
+
+// help lists the commands with their arguments and descriptions in lang,
+// the hidden ones only for admins.
+func (r *router) help(lang i18n.Lang, admin bool) string {
+	lines := []string{i18n.T(lang, "help.header")}
+	for _, c := range r.commands {
+		if c.hidden && !admin {
+			continue
+		}
+		usage := "/" + c.name
+		if c.args != "" {
+			usage += " " + c.args
+		}
+		lines = append(lines, usage+" - "+i18n.T(lang, "command."+c.name))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// PublishCommands sets the command menu users see in Telegram, in each
+// supported language. Admins also see the hidden commands in their chats.
+func (h *Handler) PublishCommands() error {
+	configs := []tgbotapi.SetMyCommandsConfig{
+		tgbotapi.NewSetMyCommands(h.router.botCommands(i18n.Default)...),
+	}
+	for _, lang := range i18n.Supported {
+		configs = append(configs, tgbotapi.NewSetMyCommandsWithScopeAndLanguage(
+			tgbotapi.NewBotCommandScopeDefault(), string(lang), h.router.botCommands(lang)...))
+	}
+
+	var all []tgbotapi.BotCommand
+	for _, c := range h.router.commands {
+		all = append(all, tgbotapi.BotCommand{Command: c.name, Description: i18n.T(i18n.Default, "command."+c.name)})
+	}
+	for adminID := range h.admins {
+		configs = append(configs, tgbotapi.NewSetMyCommandsWithScope(tgbotapi.NewBotCommandScopeChat(adminID), all...))
+	}
+
+	for _, config := range configs {
+		_, err := h.bot.Request(config)
+		if err != nil {
+			return fmt.Errorf("error bot.Request setMyCommands: %w", err)
+		}
+	}
+	log.Printf("Published %d bot commands", len(h.router.botCommands(i18n.Default)))
+	return nil
+}
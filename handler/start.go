@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"study/weatherbot/i18n"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// startCallbackPrefix starts the data of the buttons answering the /start
+// questions, followed by "<user ID>:<step>:<value>". Only the user who sent
+// /start may answer, e.g. in a group chat.
+const startCallbackPrefix = "start:"
+
+// startCallbackData returns the data of a button answering the step of the
+// /start questions asked to the user.
+func startCallbackData(userID int64, step string, value string) string {
+	return startCallbackPrefix + strconv.FormatInt(userID, 10) + ":" + step + ":" + value
+}
+
+// digestTimes are the local times of the daily digest offered to new users.
+var digestTimes = []string{"07:00", "08:00", "09:00"}
+
+// unitChoices are the unit systems offered to new users, in this order.
+var unitChoices = []string{"metric", "imperial", "kelvin"}
+
+type onboardingKey struct{}
+
+// withOnboarding stores whether the user hasn't finished the /start
+// questions yet.
+func withOnboarding(ctx context.Context, onboarding bool) context.Context {
+	return context.WithValue(ctx, onboardingKey{}, onboarding)
+}
+
+// onboardingFrom reports whether the user being served hasn't finished the
+// /start questions yet.
+func onboardingFrom(ctx context.Context) bool {
+	onboarding, _ := ctx.Value(onboardingKey{}).(bool)
+	return onboarding
+}
+
+// handleStart greets a new user and asks for their city. Then the language,
+// the units and the daily digest are asked with buttons, see
+// handleStartCallback.
+func (h *Handler) handleStart(ctx context.Context, update tgbotapi.Update) {
+	lang := langFrom(ctx)
+	if !onboardingFrom(ctx) {
+		h.replyRemovingKeyboard(update, i18n.T(lang, "start.again")+"\n\n"+h.router.help(lang, h.admins[update.Message.From.ID]))
+		return
+	}
+
+	location, err := h.userRepo.GetDefaultLocation(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.GetDefaultLocation: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if location != nil {
+		h.replyRemovingKeyboard(update, i18n.T(lang, "start.greeting_city", location.City))
+		h.askLanguage(ctx, update.Message.Chat.ID, update.Message.From.ID)
+		return
+	}
+
+	// The city is either typed as the answer or shared with the button.
+	if !h.startDialog(ctx, update, dialogStart) {
+		return
+	}
+	keyboard := tgbotapi.NewOneTimeReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButtonLocation(i18n.T(lang, "start.share_location")),
+	))
+	h.sendText(update.Message.Chat.ID, i18n.T(lang, "start.greeting"), keyboard)
+}
+
+// askLanguage asks the user the language, the /start question after the
+// city.
+func (h *Handler) askLanguage(ctx context.Context, chatID int64, userID int64) {
+	row := make([]tgbotapi.InlineKeyboardButton, 0, len(i18n.Supported))
+	for _, lang := range i18n.Supported {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "lang.name"), startCallbackData(userID, "lang", string(lang))))
+	}
+	h.sendText(chatID, i18n.T(langFrom(ctx), "start.lang"), tgbotapi.NewInlineKeyboardMarkup(row))
+}
+
+// handleStartCallback saves the answer to a /start question and asks the
+// next one, the onboarding is complete once the digest is answered.
+func (h *Handler) handleStartCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	user, data, _ := strings.Cut(strings.TrimPrefix(query.Data, startCallbackPrefix), ":")
+	step, value, _ := strings.Cut(data, ":")
+	chatID, userID := query.Message.Chat.ID, query.From.ID
+
+	if user != strconv.FormatInt(userID, 10) {
+		h.answerCallback(ctx, query, "start.not_yours")
+		return
+	}
+
+	switch step {
+	case "lang":
+		lang, ok := i18n.Parse(value)
+		if !ok {
+			h.answerCallback(ctx, query, "")
+			return
+		}
+
+		err := h.userRepo.UpdateUserLanguage(ctx, userID, string(lang))
+		if err != nil {
+			log.Println("error userRepo.UpdateUserLanguage: ", err)
+			h.answerCallback(ctx, query, "error.generic")
+			return
+		}
+
+		ctx = withLang(ctx, lang)
+		h.answerCallback(ctx, query, "")
+		h.editText(query, i18n.T(lang, "lang.set"))
+
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(unitChoices))
+		for _, name := range unitChoices {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "units."+name), startCallbackData(userID, "units", name)))
+		}
+		h.sendText(chatID, i18n.T(lang, "start.units"), tgbotapi.NewInlineKeyboardMarkup(row))
+	case "units":
+		units, ok := unitNames[value]
+		if !ok {
+			h.answerCallback(ctx, query, "")
+			return
+		}
+
+		err := h.userRepo.UpdateUserUnits(ctx, userID, string(units))
+		if err != nil {
+			log.Println("error userRepo.UpdateUserUnits: ", err)
+			h.answerCallback(ctx, query, "error.generic")
+			return
+		}
+
+		lang := langFrom(ctx)
+		h.answerCallback(ctx, query, "")
+		h.editText(query, i18n.T(lang, "units.set", i18n.T(lang, unitsName(units))))
+
+		row := make([]tgbotapi.InlineKeyboardButton, 0, len(digestTimes)+1)
+		for _, at := range digestTimes {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData(at, startCallbackData(userID, "digest", at)))
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(i18n.T(lang, "start.digest_off"), startCallbackData(userID, "digest", "off")))
+		h.sendText(chatID, i18n.T(lang, "start.digest"), tgbotapi.NewInlineKeyboardMarkup(row))
+	case "digest":
+		lang := langFrom(ctx)
+		text := i18n.T(lang, "start.digest_declined")
+		if value != "off" {
+			at, err := time.Parse("15:04", value)
+			if err != nil {
+				h.answerCallback(ctx, query, "")
+				return
+			}
+			key, args := h.subscribe(ctx, userID, chatID, at)
+			text = i18n.T(lang, key, args...)
+		}
+		h.answerCallback(ctx, query, "")
+		h.editText(query, text)
+
+		err := h.userRepo.CompleteOnboarding(ctx, userID)
+		if err != nil {
+			log.Println("error userRepo.CompleteOnboarding: ", err)
+		}
+		h.sendText(chatID, i18n.T(lang, "start.done")+"\n\n"+h.router.help(lang, h.admins[userID]), nil)
+	default:
+		h.answerCallback(ctx, query, "")
+	}
+}
+
+func (h *Handler) handleHelp(ctx context.Context, update tgbotapi.Update) {
+	h.replyText(update, h.router.help(langFrom(ctx), h.admins[update.Message.From.ID]))
+}
+
+// sendText sends the text to the chat with the keyboard, if any.
+func (h *Handler) sendText(chatID int64, text string, keyboard any) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	_, err := h.bot.Send(msg)
+	if err != nil {
+		log.Println("error bot.Send: ", err)
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// WithAdmins lets the users with the given IDs run the admin commands.
+func WithAdmins(ids ...int64) Option {
+	return func(h *Handler) {
+		for _, id := range ids {
+			h.admins[id] = true
+		}
+	}
+}
+
+// WithStats reports stats under name in /stats, e.g. the hits of a cache.
+func WithStats(name string, stats func() any) Option {
+	return func(h *Handler) {
+		h.stats[name] = stats
+	}
+}
+
+// handleStats sends the admin the same stats as the metrics endpoint.
+func (h *Handler) handleStats(ctx context.Context, update tgbotapi.Update) {
+	stats := make(map[string]any, len(h.stats))
+	for name, f := range h.stats {
+		stats[name] = f()
+	}
+
+	text, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		log.Println("error json.MarshalIndent: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+	h.replyText(update, string(text))
+}
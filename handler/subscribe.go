@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/models"
+	"study/weatherbot/scheduler"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+var errCityNotSet = errors.New("city is not set")
+
+func (h *Handler) handleSubscribe(ctx context.Context, update tgbotapi.Update) {
+	at, err := time.Parse("15:04", strings.TrimSpace(update.Message.CommandArguments()))
+	if err != nil {
+		h.reply(ctx, update, "subscribe.usage")
+		return
+	}
+
+	key, args := h.subscribe(ctx, update.Message.From.ID, update.Message.Chat.ID, at)
+	h.reply(ctx, update, key, args...)
+}
+
+// subscribe sends the daily digest of the user's default location to the
+// chat at the local time of the location. It returns the message key and
+// arguments to answer the user with.
+func (h *Handler) subscribe(ctx context.Context, userID int64, chatID int64, at time.Time) (string, []any) {
+	location, err := h.userRepo.GetDefaultLocation(ctx, userID)
+	if err != nil {
+		log.Println("error userRepo.GetDefaultLocation: ", err)
+		return "error.generic", nil
+	}
+
+	if location == nil {
+		return "city.not_set", nil
+	}
+	city := location.City
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// The delivery time is local to the city, its UTC offset is only known
+	// from the weather response.
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
+	if err != nil {
+		return providerErrorMessage(err, "weather.coordinates_failed")
+	}
+
+	current, err := h.owProvider.Weather(weatherCtx, lat, lon, weatherParams(ctx))
+	if err != nil {
+		return providerErrorMessage(err, "weather.failed")
+	}
+
+	notifyAt := at.Hour()*60 + at.Minute()
+	err = h.userRepo.UpsertSubscription(ctx, models.Subscription{
+		UserID:    userID,
+		ChatID:    chatID,
+		NotifyAt:  notifyAt,
+		TZOffset:  current.Timezone,
+		NextRunAt: scheduler.NextRun(time.Now(), notifyAt, current.Timezone),
+	})
+	if err != nil {
+		log.Println("error userRepo.UpsertSubscription: ", err)
+		return "subscribe.failed", nil
+	}
+
+	return "subscribe.saved", []any{at.Format("15:04"), city}
+}
+
+func (h *Handler) handleUnsubscribe(ctx context.Context, update tgbotapi.Update) {
+	deleted, err := h.userRepo.DeleteSubscription(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.DeleteSubscription: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !deleted {
+		h.reply(ctx, update, "unsubscribe.none")
+		return
+	}
+
+	h.reply(ctx, update, "unsubscribe.done")
+}
+
+// Digest builds the daily digest for the user and reports the current UTC
+// offset of the user's city so the scheduler can follow DST changes.
+func (h *Handler) Digest(ctx context.Context, userID int64) (string, int, error) {
+	lang, units, err := h.userPrefs(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	params := weather.Params{Lang: string(lang), Units: units}
+
+	location, err := h.userRepo.GetDefaultLocation(ctx, userID)
+	if err != nil {
+		return "", 0, fmt.Errorf("error userRepo.GetDefaultLocation: %w", err)
+	}
+
+	if location == nil {
+		return "", 0, errCityNotSet
+	}
+	city := location.City
+
+	lat, lon, err := h.locationCoordinates(ctx, location)
+	if err != nil {
+		return "", 0, fmt.Errorf("error h.locationCoordinates: %w", err)
+	}
+
+	current, err := h.owProvider.Weather(ctx, lat, lon, params)
+	if err != nil {
+		return "", 0, fmt.Errorf("error owProvider.Weather: %w", err)
+	}
+
+	forecast, err := h.owProvider.Forecast(ctx, lat, lon, params)
+	if err != nil {
+		return "", 0, fmt.Errorf("error owProvider.Forecast: %w", err)
+	}
+
+	text := formatWeather(lang, units, city, current) + "\n\n" + formatForecast(lang, units, city, forecast.Days, 2)
+	return text, current.Timezone, nil
+}
+
+// userPrefs returns the language and units of a user outside of an update,
+// e.g. for messages sent in the background.
+func (h *Handler) userPrefs(ctx context.Context, userID int64) (i18n.Lang, weather.Units, error) {
+	user, err := h.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		return "", "", fmt.Errorf("error userRepo.GetUser: %w", err)
+	}
+	if user == nil {
+		return i18n.Default, weather.Metric, nil
+	}
+	return userLang(user.Language), userUnits(user.Units), nil
+}
@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"context"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/weather"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// unitNames maps the unit systems offered with /units to the provider ones.
+var unitNames = map[string]weather.Units{
+	"metric":   weather.Metric,
+	"imperial": weather.Imperial,
+	"kelvin":   weather.Standard,
+}
+
+type unitsKey struct{}
+
+// withUnits stores the unit system of the user the update came from.
+func withUnits(ctx context.Context, units weather.Units) context.Context {
+	return context.WithValue(ctx, unitsKey{}, units)
+}
+
+// unitsFrom returns the unit system of the user being served.
+func unitsFrom(ctx context.Context) weather.Units {
+	units, ok := ctx.Value(unitsKey{}).(weather.Units)
+	if !ok {
+		return weather.Metric
+	}
+	return units
+}
+
+// userUnits returns the saved unit system of the user, metric for unknown
+// values.
+func userUnits(units string) weather.Units {
+	switch u := weather.Units(units); u {
+	case weather.Metric, weather.Imperial, weather.Standard:
+		return u
+	default:
+		return weather.Metric
+	}
+}
+
+// unitsName returns the catalog key describing the unit system.
+func unitsName(units weather.Units) string {
+	for name, u := range unitNames {
+		if u == units {
+			return "units." + name
+		}
+	}
+	return "units.metric"
+}
+
+func (h *Handler) handleUnits(ctx context.Context, update tgbotapi.Update) {
+	arg := strings.ToLower(strings.TrimSpace(update.Message.CommandArguments()))
+	if arg == "" {
+		h.reply(ctx, update, "units.current", i18n.T(langFrom(ctx), unitsName(unitsFrom(ctx))))
+		return
+	}
+
+	units, ok := unitNames[arg]
+	if !ok {
+		h.reply(ctx, update, "units.unknown")
+		return
+	}
+
+	err := h.userRepo.UpdateUserUnits(ctx, update.Message.From.ID, string(units))
+	if err != nil {
+		log.Println("error userRepo.UpdateUserUnits: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	h.reply(ctx, update, "units.set", i18n.T(langFrom(ctx), unitsName(units)))
+}
@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"study/weatherbot/i18n"
+	"study/weatherbot/models"
+	"study/weatherbot/weather"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// handleWarnings lists the official warnings for a location, "on" and "off"
+// subscribe to new warnings for the default location.
+func (h *Handler) handleWarnings(ctx context.Context, update tgbotapi.Update) {
+	arg := normalizeLocationName(update.Message.CommandArguments())
+	switch arg {
+	case "on":
+		h.handleEnableWarnings(ctx, update)
+		return
+	case "off":
+		h.handleDisableWarnings(ctx, update)
+		return
+	}
+
+	location, ok := h.findLocation(ctx, update, arg)
+	if !ok {
+		return
+	}
+
+	weatherCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	lat, lon, err := h.locationCoordinates(weatherCtx, location)
+	if err != nil {
+		h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+		return
+	}
+
+	alerts, err := h.owProvider.Alerts(weatherCtx, lat, lon)
+	if errors.Is(err, weather.ErrNotSupported) {
+		h.reply(ctx, update, "warning.not_supported")
+		return
+	}
+	if err != nil {
+		log.Println("error owProvider.Alerts: ", err)
+		h.replyProviderError(ctx, update, err, "warning.failed")
+		return
+	}
+
+	if len(alerts) == 0 {
+		h.reply(ctx, update, "warning.none", location.City)
+		return
+	}
+
+	lang := langFrom(ctx)
+	items := make([]string, 0, len(alerts)+1)
+	items = append(items, i18n.T(lang, "warning.header", location.City))
+	for _, alert := range alerts {
+		items = append(items, formatWarning(lang, alert))
+	}
+
+	h.replyText(update, strings.Join(items, "\n\n"))
+}
+
+func (h *Handler) handleEnableWarnings(ctx context.Context, update tgbotapi.Update) {
+	location, ok := h.findLocation(ctx, update, "")
+	if !ok {
+		return
+	}
+
+	// Warnings are only checked for locations with coordinates.
+	if !location.Geocoded {
+		_, _, err := h.locationCoordinates(ctx, location)
+		if err != nil {
+			h.replyProviderError(ctx, update, err, "weather.coordinates_failed")
+			return
+		}
+	}
+
+	err := h.userRepo.EnableWarnings(ctx, update.Message.From.ID, update.Message.Chat.ID)
+	if err != nil {
+		log.Println("error userRepo.EnableWarnings: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	h.reply(ctx, update, "warning.enabled", location.City)
+}
+
+func (h *Handler) handleDisableWarnings(ctx context.Context, update tgbotapi.Update) {
+	disabled, err := h.userRepo.DisableWarnings(ctx, update.Message.From.ID)
+	if err != nil {
+		log.Println("error userRepo.DisableWarnings: ", err)
+		h.reply(ctx, update, "error.generic")
+		return
+	}
+
+	if !disabled {
+		h.reply(ctx, update, "warning.not_enabled")
+		return
+	}
+
+	h.reply(ctx, update, "warning.disabled")
+}
+
+// WarningMessage builds the notification about a new official warning in the
+// language of the user.
+func (h *Handler) WarningMessage(ctx context.Context, userID int64, location models.Location, alert weather.Alert) (string, error) {
+	lang, _, err := h.userPrefs(ctx, userID)
+	if err != nil {
+		return "", err
+	}
+
+	return i18n.T(lang, "warning.new", location.City) + "\n\n" + formatWarning(lang, alert), nil
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const secretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+type WebhookConfig struct {
+	ListenAddr string
+	Path       string
+	Secret     string
+}
+
+// StartWebhook receives updates pushed by Telegram to an HTTP server until
+// ctx is cancelled. The webhook itself must already be registered with the
+// same secret token.
+func (h *Handler) StartWebhook(ctx context.Context, cfg WebhookConfig) error {
+	updates := make(chan tgbotapi.Update)
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Path, newWebhookHandler(ctx, cfg.Secret, updates))
+
+	server := &http.Server{
+		Addr:              cfg.ListenAddr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	serveCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errCh := make(chan error, 1)
+	go func() {
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+			cancel()
+		}
+	}()
+
+	log.Printf("Bot handler listening for webhook on %s%s", cfg.ListenAddr, cfg.Path)
+
+	h.serve(serveCtx, updates)
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.WithoutCancel(ctx), 5*time.Second)
+	defer shutdownCancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Println("error server.Shutdown: ", err)
+	}
+
+	select {
+	case err := <-errCh:
+		return fmt.Errorf("error server.ListenAndServe: %w", err)
+	default:
+	}
+
+	log.Println("Bot handler stopped gracefully.")
+	return nil
+}
+
+// newWebhookHandler accepts updates from Telegram and passes them to
+// updates. Requests without the expected secret token are rejected.
+func newWebhookHandler(ctx context.Context, secret string, updates chan<- tgbotapi.Update) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		token := r.Header.Get(secretTokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var update tgbotapi.Update
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&update)
+		if err != nil {
+			log.Println("error decode webhook update: ", err)
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		select {
+		case updates <- update:
+			w.WriteHeader(http.StatusOK)
+		case <-ctx.Done():
+			// Telegram redelivers the update once the bot is back.
+			http.Error(w, "shutting down", http.StatusServiceUnavailable)
+		case <-r.Context().Done():
+		}
+	})
+}
@@ -0,0 +1,190 @@
+package i18n
+
+var en = map[string]string{
+	"lang.name": "English",
+
+	"error.generic":        "Something went wrong",
+	"command.unknown":      "This command is not available",
+	"message.use_commands": "Please use the available commands",
+
+	"command.start":       "Get started",
+	"command.help":        "List of commands",
+	"command.weather":     "Current weather",
+	"command.forecast":    "Forecast for several days",
+	"command.rain":        "Will it rain in the next 2 hours",
+	"command.air":         "Air quality",
+	"command.warnings":    "Official severe weather warnings",
+	"command.city":        "Choose your city",
+	"command.addcity":     "Save a place",
+	"command.cities":      "Saved places",
+	"command.delcity":     "Delete a place",
+	"command.default":     "Choose the default place",
+	"command.subscribe":   "Daily weather digest",
+	"command.unsubscribe": "Stop the daily digest",
+	"command.alert":       "Alert on frost, rain or wind",
+	"command.alerts":      "Your alerts",
+	"command.delalert":    "Delete an alert",
+	"command.lang":        "Bot language",
+	"command.units":       "Units of measurement",
+	"command.cancel":      "Cancel the bot's question",
+	"command.stats":       "Weather cache and provider stats",
+
+	"provider.unauthorized": "The weather service rejected the bot's request. We're looking into it, please try later",
+	"provider.rate_limited": "The weather service has too many requests, please try again in %d min",
+	"provider.upstream":     "The weather service is not responding right now, please try later",
+
+	"rate.limited": "Too many requests, please slow down and try again in a minute",
+
+	"city.ask":               "Which city should I save? Type its name or /cancel",
+	"city.too_short":         "The city name is too short",
+	"city.not_found":         "City '%s' was not found. Please check the spelling.",
+	"city.lookup_failed":     "Could not check the city. Please try again later.",
+	"city.choose":            "Several cities found, please choose one:",
+	"city.choice_expired":    "This choice has expired, please repeat the command",
+	"city.choice_not_yours":  "Only the author of the command can choose the city",
+	"city.saved":             "City %s saved",
+	"city.saved_place":       "City %s saved for your default place %s",
+	"city.save_failed":       "Could not save city %s",
+	"city.not_set":           "Save your city first - /city <your city>",
+	"city.reverse_not_found": "Could not find a city at this location",
+	"city.reverse_failed":    "Could not detect the city. Please try again later.",
+
+	"location.add_usage":     "Please specify a place name and a city: /addcity <name> <city>",
+	"location.name_too_long": "The place name must be at most %d characters long",
+	"location.saved":         "Place %s (%s) saved",
+	"location.not_found":     "Place '%s' was not found. Your saved places - /cities",
+	"location.list_empty":    "You have no saved places. Add one - /addcity <name> <city>",
+	"location.list_header":   "Your places:",
+	"location.list_item":     "%s - %s",
+	"location.default_mark":  " (default)",
+	"location.delete_usage":  "Please specify a place name: /delcity <name>",
+	"location.deleted":       "Place %s deleted",
+	"location.default_usage": "Please specify a place name: /default <name>",
+	"location.default_set":   "Place %s is now the default",
+
+	"weather.coordinates_failed": "Could not get the coordinates",
+	"weather.failed":             "Could not get the weather for this place",
+	"weather.header":             "Weather in your city \n%s: %s, %s",
+	"weather.feels_like":         "Feels like: %s",
+	"weather.humidity":           "Humidity: %d%%",
+	"weather.pressure":           "Pressure: %s",
+	"weather.wind":               "Wind: %s, %s",
+	"weather.gusts":              ", gusts up to %s",
+	"weather.clouds":             "Cloudiness: %d%%",
+	"weather.visibility":         "Visibility: %s",
+	"weather.rain":               "Rain: %.1f mm/h",
+	"weather.snow":               "Snow: %.1f mm/h",
+	"weather.sun":                "Sunrise: %s, sunset: %s",
+
+	"forecast.usage":  "Please specify from 1 to %d days: /forecast [days]",
+	"forecast.failed": "Could not get the forecast for this place",
+	"forecast.header": "Weather forecast \n%s:",
+	"forecast.day":    "%s %s: %d…%d%s, %s",
+
+	"unit.speed.ms":      "%d m/s",
+	"unit.speed.mph":     "%d mph",
+	"unit.pressure.hpa":  "%d hPa",
+	"unit.pressure.mmhg": "%d mmHg",
+	"unit.pressure.inhg": "%.2f inHg",
+	"unit.distance.km":   "%.1f km",
+	"unit.distance.mi":   "%.1f mi",
+
+	"weekday.0": "Sun",
+	"weekday.1": "Mon",
+	"weekday.2": "Tue",
+	"weekday.3": "Wed",
+	"weekday.4": "Thu",
+	"weekday.5": "Fri",
+	"weekday.6": "Sat",
+
+	"wind.0": "N",
+	"wind.1": "NE",
+	"wind.2": "E",
+	"wind.3": "SE",
+	"wind.4": "S",
+	"wind.5": "SW",
+	"wind.6": "W",
+	"wind.7": "NW",
+
+	"subscribe.usage":  "Please specify the time in your city's local time: /subscribe HH:MM",
+	"subscribe.failed": "Could not save the subscription",
+	"subscribe.saved":  "The daily digest will arrive at %s local time in %s",
+	"unsubscribe.none": "You are not subscribed to the daily digest",
+	"unsubscribe.done": "Unsubscribed from the daily digest",
+
+	"alert.usage":        "Please specify a condition and a threshold: /alert cold <temperature> - tomorrow's minimum temperature below the threshold, /alert rain <percent> - chance of precipitation within 6 hours above the threshold, /alert wind <speed> - wind gusts within a day stronger than the threshold. A place name may be added at the end.",
+	"alert.rain_range":   "The chance of precipitation is a percentage from 0 to 100",
+	"alert.too_many":     "You can have at most %d alerts. Delete an alert - /delalert <number>",
+	"alert.failed":       "Could not save the alert",
+	"alert.saved":        "Alert #%d for %s saved: %s",
+	"alert.list_empty":   "You have no alerts. Add an alert - /alert <condition> <threshold>",
+	"alert.list_header":  "Your alerts:",
+	"alert.list_item":    "#%d %s: %s",
+	"alert.delete_usage": "Please specify the alert number: /delalert <number>",
+	"alert.not_found":    "Alert #%d not found. List of alerts - /alerts",
+	"alert.deleted":      "Alert #%d deleted",
+	"alert.rule.cold":    "tomorrow's minimum temperature below %s",
+	"alert.rule.rain":    "chance of precipitation within 6 hours above %d%%",
+	"alert.rule.wind":    "wind gusts within a day stronger than %s",
+	"alert.fired.cold":   "Alert #%d: tomorrow's minimum temperature in %s is %s",
+	"alert.fired.rain":   "Alert #%d: in %s at %s the chance of precipitation is %d%%",
+	"alert.fired.wind":   "Alert #%d: in %s at %s wind gusts reach %s",
+
+	"warning.failed":        "Could not get the warnings for this place",
+	"warning.not_supported": "The current weather source doesn't provide official warnings",
+	"warning.none":          "No warnings in effect for %s",
+	"warning.header":        "Warnings for %s:",
+	"warning.item":          "%s\n%s - %s\nSource: %s\n%s",
+	"warning.new":           "New warning for %s:",
+	"warning.enabled":       "New warnings for your default place (%s) will be sent to this chat. Turn off - /warnings off",
+	"warning.disabled":      "Warning notifications turned off",
+	"warning.not_enabled":   "Warning notifications are not turned on. Turn on - /warnings on",
+
+	"air.failed":    "Could not get the air quality for this place",
+	"air.header":    "Air quality in %s: %s (AQI %d)",
+	"air.component": "%s: %.1f µg/m³ - %s",
+	"air.forecast":  "Worst within 24 hours: %s (AQI %d)",
+	"air.level.1":   "good",
+	"air.level.2":   "fair",
+	"air.level.3":   "moderate",
+	"air.level.4":   "poor",
+	"air.level.5":   "very poor",
+
+	"rain.failed":        "Could not get the precipitation forecast for this place",
+	"rain.not_supported": "The current weather source doesn't provide a minute by minute precipitation forecast",
+	"rain.header":        "Precipitation in %s for the next 2 hours:",
+	"rain.none":          "No precipitation expected",
+	"rain.starts":        "Precipitation starts at about %s",
+	"rain.stops":         "Precipitating now, stops at about %s",
+	"rain.continues":     "Precipitating now, won't stop before %s",
+	"rain.chance":        "Chance of precipitation: %d%%",
+	"rain.legend":        "· dry ▂ drizzle ▄ light ▆ moderate █ heavy",
+
+	"lang.current": "Current language: %s. Change it: /lang <%s>",
+	"lang.unknown": "Available languages: %s",
+	"lang.set":     "Language set to English",
+
+	"units.current":  "Units: %s. Change them: /units <metric|imperial|kelvin>",
+	"units.unknown":  "Available units: metric, imperial, kelvin",
+	"units.set":      "Units set to %s",
+	"units.metric":   "metric (°C, m/s, hPa)",
+	"units.imperial": "imperial (°F, mph, inHg)",
+	"units.kelvin":   "Kelvin (K, m/s, hPa)",
+
+	"start.greeting":        "Hi! I'll tell you the weather and the forecast and warn you about rain. Type your city or share your location",
+	"start.greeting_city":   "Hi! Your city is %s",
+	"start.share_location":  "Share location",
+	"start.lang":            "Which language should I reply in?",
+	"start.units":           "Which units should I show the weather in?",
+	"start.digest":          "Send you a weather digest every morning? The time is local to your city, you can change it with /subscribe",
+	"start.digest_off":      "No, thanks",
+	"start.digest_declined": "Fine, you can turn the digest on later with /subscribe",
+	"start.done":            "All set!",
+	"start.again":           "Your settings are already saved, you can change them with the commands below",
+	"start.not_yours":       "Only the user who sent /start can answer",
+
+	"help.header": "Commands:",
+
+	"cancel.done":    "OK, cancelled",
+	"cancel.nothing": "There is no question to cancel",
+}
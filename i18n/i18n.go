@@ -0,0 +1,66 @@
+// Package i18n holds the message catalogs of the bot replies.
+package i18n
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+type Lang string
+
+const (
+	Russian Lang = "ru"
+	English Lang = "en"
+
+	Default = Russian
+)
+
+var catalogs = map[Lang]map[string]string{
+	Russian: ru,
+	English: en,
+}
+
+// Supported lists the languages with a catalog, in the order they are
+// offered to users.
+var Supported = []Lang{Russian, English}
+
+// Parse returns the supported language with the given code.
+func Parse(code string) (Lang, bool) {
+	lang := Lang(strings.ToLower(strings.TrimSpace(code)))
+	_, ok := catalogs[lang]
+	return lang, ok
+}
+
+// FromLanguageCode picks the language for a Telegram user's IETF language
+// tag. Russian speakers and users without a tag get the default language,
+// everyone else gets English.
+func FromLanguageCode(code string) Lang {
+	if code == "" {
+		return Default
+	}
+
+	base, _, _ := strings.Cut(strings.ToLower(code), "-")
+	if lang, ok := Parse(base); ok {
+		return lang
+	}
+	return English
+}
+
+// T returns the message for key in lang formatted with args. Messages
+// missing from the catalog fall back to the default language.
+func T(lang Lang, key string, args ...any) string {
+	msg, ok := catalogs[lang][key]
+	if !ok {
+		msg, ok = catalogs[Default][key]
+	}
+	if !ok {
+		log.Printf("i18n: missing message %q", key)
+		return key
+	}
+
+	if len(args) == 0 {
+		return msg
+	}
+	return fmt.Sprintf(msg, args...)
+}
@@ -0,0 +1,64 @@
+package i18n
+
+import "testing"
+
+func TestCatalogsHaveSameKeys(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for key := range catalogs[Default] {
+			if _, ok := catalog[key]; !ok {
+				t.Errorf("%s catalog misses %q", lang, key)
+			}
+		}
+		for key := range catalog {
+			if _, ok := catalogs[Default][key]; !ok {
+				t.Errorf("%s catalog has %q missing from the default one", lang, key)
+			}
+		}
+	}
+}
+
+func TestFromLanguageCode(t *testing.T) {
+	tests := []struct {
+		code string
+		want Lang
+	}{
+		{"", Russian},
+		{"ru", Russian},
+		{"en", English},
+		{"en-US", English},
+		{"RU-ru", Russian},
+		{"de", English},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			if got := FromLanguageCode(tt.code); got != tt.want {
+				t.Errorf("FromLanguageCode(%q) = %v, want %v", tt.code, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		name string
+		lang Lang
+		key  string
+		args []any
+		want string
+	}{
+		{"Russian", Russian, "city.saved", []any{"Moscow"}, "Город Moscow успешно сохранен"},
+		{"English", English, "city.saved", []any{"Moscow"}, "City Moscow saved"},
+		{"Unknown language", Lang("de"), "error.generic", nil, "Произошла ошибка"},
+		{"Unknown key", English, "no.such.key", nil, "no.such.key"},
+		{"Percent without args", Russian, "weather.humidity", []any{80}, "Влажность: 80%"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := T(tt.lang, tt.key, tt.args...); got != tt.want {
+				t.Errorf("T() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
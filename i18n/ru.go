@@ -0,0 +1,190 @@
+package i18n
+
+var ru = map[string]string{
+	"lang.name": "русский",
+
+	"error.generic":        "Произошла ошибка",
+	"command.unknown":      "Такая команда не доступна",
+	"message.use_commands": "Воспользуйтесь доступными командами",
+
+	"command.start":       "Начать",
+	"command.help":        "Список команд",
+	"command.weather":     "Погода сейчас",
+	"command.forecast":    "Прогноз на несколько дней",
+	"command.rain":        "Будут ли осадки в ближайшие 2 часа",
+	"command.air":         "Качество воздуха",
+	"command.warnings":    "Официальные предупреждения о непогоде",
+	"command.city":        "Выбрать город",
+	"command.addcity":     "Сохранить место",
+	"command.cities":      "Сохраненные места",
+	"command.delcity":     "Удалить место",
+	"command.default":     "Выбрать место по умолчанию",
+	"command.subscribe":   "Ежедневная сводка погоды",
+	"command.unsubscribe": "Отписаться от сводки",
+	"command.alert":       "Уведомление о морозе, дожде или ветре",
+	"command.alerts":      "Ваши уведомления",
+	"command.delalert":    "Удалить уведомление",
+	"command.lang":        "Язык бота",
+	"command.units":       "Единицы измерения",
+	"command.cancel":      "Отменить вопрос бота",
+	"command.stats":       "Статистика кэша и провайдеров погоды",
+
+	"provider.unauthorized": "Сервис погоды отклонил запрос бота. Мы уже разбираемся, попробуйте позже",
+	"provider.rate_limited": "Сервис погоды перегружен запросами, попробуйте через %d мин.",
+	"provider.upstream":     "Сервис погоды сейчас не отвечает, попробуйте позже",
+
+	"rate.limited": "Слишком много запросов, пожалуйста, помедленнее. Попробуйте через минуту",
+
+	"city.ask":               "Какой город сохранить? Напишите название или /cancel для отмены",
+	"city.too_short":         "Название города слишком короткое",
+	"city.not_found":         "Город '%s' не найден. Пожалуйста, проверьте правильность написания.",
+	"city.lookup_failed":     "Произошла ошибка при проверке города. Попробуйте позже.",
+	"city.choose":            "Найдено несколько городов, выберите нужный:",
+	"city.choice_expired":    "Выбор устарел, повторите команду",
+	"city.choice_not_yours":  "Город выбирает автор команды",
+	"city.saved":             "Город %s успешно сохранен",
+	"city.saved_place":       "Город %s сохранен для места по умолчанию %s",
+	"city.save_failed":       "Ошибка при попытке сохранения города - %s",
+	"city.not_set":           "Сначала сохраните ваш город - /city <your city>",
+	"city.reverse_not_found": "Не удалось определить город по этой геопозиции",
+	"city.reverse_failed":    "Произошла ошибка при определении города. Попробуйте позже.",
+
+	"location.add_usage":     "Пожалуйста, укажите название места и город: /addcity <название> <город>",
+	"location.name_too_long": "Название места должно быть не длиннее %d символов",
+	"location.saved":         "Место %s (%s) успешно сохранено",
+	"location.not_found":     "Место '%s' не найдено. Список сохраненных мест - /cities",
+	"location.list_empty":    "У вас нет сохраненных мест. Добавьте место - /addcity <название> <город>",
+	"location.list_header":   "Ваши места:",
+	"location.list_item":     "%s - %s",
+	"location.default_mark":  " (по умолчанию)",
+	"location.delete_usage":  "Пожалуйста, укажите название места: /delcity <название>",
+	"location.deleted":       "Место %s удалено",
+	"location.default_usage": "Пожалуйста, укажите название места: /default <название>",
+	"location.default_set":   "Место %s выбрано по умолчанию",
+
+	"weather.coordinates_failed": "Не смогли получить координаты",
+	"weather.failed":             "Не смогли получить погоду в этой местности",
+	"weather.header":             "Погода в вашем городе \n%s: %s, %s",
+	"weather.feels_like":         "Ощущается как: %s",
+	"weather.humidity":           "Влажность: %d%%",
+	"weather.pressure":           "Давление: %s",
+	"weather.wind":               "Ветер: %s, %s",
+	"weather.gusts":              ", порывы до %s",
+	"weather.clouds":             "Облачность: %d%%",
+	"weather.visibility":         "Видимость: %s",
+	"weather.rain":               "Дождь: %.1f мм/ч",
+	"weather.snow":               "Снег: %.1f мм/ч",
+	"weather.sun":                "Восход: %s, закат: %s",
+
+	"forecast.usage":  "Укажите количество дней от 1 до %d: /forecast [дни]",
+	"forecast.failed": "Не смогли получить прогноз в этой местности",
+	"forecast.header": "Прогноз погоды \n%s:",
+	"forecast.day":    "%s %s: %d…%d%s, %s",
+
+	"unit.speed.ms":      "%d м/с",
+	"unit.speed.mph":     "%d миль/ч",
+	"unit.pressure.hpa":  "%d гПа",
+	"unit.pressure.mmhg": "%d мм рт. ст.",
+	"unit.pressure.inhg": "%.2f дюйма рт. ст.",
+	"unit.distance.km":   "%.1f км",
+	"unit.distance.mi":   "%.1f миль",
+
+	"weekday.0": "Вс",
+	"weekday.1": "Пн",
+	"weekday.2": "Вт",
+	"weekday.3": "Ср",
+	"weekday.4": "Чт",
+	"weekday.5": "Пт",
+	"weekday.6": "Сб",
+
+	"wind.0": "С",
+	"wind.1": "СВ",
+	"wind.2": "В",
+	"wind.3": "ЮВ",
+	"wind.4": "Ю",
+	"wind.5": "ЮЗ",
+	"wind.6": "З",
+	"wind.7": "СЗ",
+
+	"subscribe.usage":  "Укажите время по местному времени вашего города: /subscribe ЧЧ:ММ",
+	"subscribe.failed": "Не удалось сохранить подписку",
+	"subscribe.saved":  "Ежедневная сводка будет приходить в %s по местному времени города %s",
+	"unsubscribe.none": "У вас нет подписки на ежедневную сводку",
+	"unsubscribe.done": "Подписка на ежедневную сводку отменена",
+
+	"alert.usage":        "Укажите условие и порог: /alert cold <температура> - минимальная температура завтра ниже порога, /alert rain <процент> - вероятность осадков в ближайшие 6 часов выше порога, /alert wind <скорость> - порывы ветра в ближайшие сутки сильнее порога. В конце можно указать название места.",
+	"alert.rain_range":   "Вероятность осадков указывается в процентах от 0 до 100",
+	"alert.too_many":     "Можно создать не больше %d оповещений. Удалить оповещение - /delalert <номер>",
+	"alert.failed":       "Не удалось сохранить оповещение",
+	"alert.saved":        "Оповещение #%d для места %s сохранено: %s",
+	"alert.list_empty":   "У вас нет оповещений. Добавьте оповещение - /alert <условие> <порог>",
+	"alert.list_header":  "Ваши оповещения:",
+	"alert.list_item":    "#%d %s: %s",
+	"alert.delete_usage": "Укажите номер оповещения: /delalert <номер>",
+	"alert.not_found":    "Оповещение #%d не найдено. Список оповещений - /alerts",
+	"alert.deleted":      "Оповещение #%d удалено",
+	"alert.rule.cold":    "минимальная температура завтра ниже %s",
+	"alert.rule.rain":    "вероятность осадков в ближайшие 6 часов выше %d%%",
+	"alert.rule.wind":    "порывы ветра в ближайшие сутки сильнее %s",
+	"alert.fired.cold":   "Оповещение #%d: завтра в %s минимальная температура %s",
+	"alert.fired.rain":   "Оповещение #%d: в %s в %s вероятность осадков %d%%",
+	"alert.fired.wind":   "Оповещение #%d: в %s в %s порывы ветра до %s",
+
+	"warning.failed":        "Не смогли получить предупреждения для этой местности",
+	"warning.not_supported": "Текущий источник погоды не передает официальные предупреждения",
+	"warning.none":          "Для %s нет действующих предупреждений",
+	"warning.header":        "Предупреждения для %s:",
+	"warning.item":          "%s\n%s - %s\nИсточник: %s\n%s",
+	"warning.new":           "Новое предупреждение для %s:",
+	"warning.enabled":       "Новые предупреждения для места по умолчанию (%s) будут приходить в этот чат. Отключить - /warnings off",
+	"warning.disabled":      "Уведомления о предупреждениях отключены",
+	"warning.not_enabled":   "Уведомления о предупреждениях не включены. Включить - /warnings on",
+
+	"air.failed":    "Не смогли получить качество воздуха в этой местности",
+	"air.header":    "Качество воздуха в %s: %s (AQI %d)",
+	"air.component": "%s: %.1f мкг/м³ - %s",
+	"air.forecast":  "Худшее в ближайшие сутки: %s (AQI %d)",
+	"air.level.1":   "хорошее",
+	"air.level.2":   "удовлетворительное",
+	"air.level.3":   "умеренное",
+	"air.level.4":   "плохое",
+	"air.level.5":   "очень плохое",
+
+	"rain.failed":        "Не смогли получить прогноз осадков для этой местности",
+	"rain.not_supported": "Текущий источник погоды не передает поминутный прогноз осадков",
+	"rain.header":        "Осадки в %s на ближайшие 2 часа:",
+	"rain.none":          "Осадков не ожидается",
+	"rain.starts":        "Осадки начнутся около %s",
+	"rain.stops":         "Сейчас идут осадки, закончатся около %s",
+	"rain.continues":     "Сейчас идут осадки, не закончатся до %s",
+	"rain.chance":        "Вероятность осадков: %d%%",
+	"rain.legend":        "· сухо ▂ морось ▄ слабые ▆ умеренные █ сильные",
+
+	"lang.current": "Текущий язык: %s. Изменить: /lang <%s>",
+	"lang.unknown": "Доступные языки: %s",
+	"lang.set":     "Язык изменен на русский",
+
+	"units.current":  "Единицы измерения: %s. Изменить: /units <metric|imperial|kelvin>",
+	"units.unknown":  "Доступные единицы измерения: metric, imperial, kelvin",
+	"units.set":      "Единицы измерения изменены: %s",
+	"units.metric":   "метрические (°C, м/с, мм рт. ст.)",
+	"units.imperial": "имперские (°F, миль/ч, дюймы рт. ст.)",
+	"units.kelvin":   "кельвины (K, м/с, гПа)",
+
+	"start.greeting":        "Привет! Я подскажу погоду, прогноз и предупрежу о дожде. Напишите ваш город или поделитесь местоположением",
+	"start.greeting_city":   "Привет! Ваш город - %s",
+	"start.share_location":  "Отправить местоположение",
+	"start.lang":            "На каком языке вам отвечать?",
+	"start.units":           "В каких единицах показывать погоду?",
+	"start.digest":          "Присылать сводку погоды каждое утро? Время местное для вашего города, изменить можно командой /subscribe",
+	"start.digest_off":      "Не нужно",
+	"start.digest_declined": "Хорошо, сводку можно включить позже командой /subscribe",
+	"start.done":            "Все готово!",
+	"start.again":           "Настройки уже сохранены, их можно изменить командами ниже",
+	"start.not_yours":       "Ответить может только тот, кто отправил /start",
+
+	"help.header": "Команды:",
+
+	"cancel.done":    "Хорошо, отменено",
+	"cancel.nothing": "Бот ничего не спрашивал",
+}
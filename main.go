@@ -2,14 +2,28 @@ package main
 
 import (
 	"context"
+	"errors"
+	"expvar"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
+	"study/weatherbot/alerts"
+	"study/weatherbot/clients/cache"
+	"study/weatherbot/clients/failover"
+	"study/weatherbot/clients/openmeteo"
 	"study/weatherbot/clients/openweather"
 	"study/weatherbot/config"
 	"study/weatherbot/handler"
 	"study/weatherbot/repo"
+	"study/weatherbot/scheduler"
+	"study/weatherbot/weather"
+	"sync"
 	"syscall"
+	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -44,11 +58,179 @@ func main() {
 
 	log.Printf("Authorized on account %s", bot.Self.UserName)
 
-	owClient := openweather.New(cfg.OpenWeatherAPIKey)
+	openWeatherOpts, err := openWeatherOptions(cfg)
+	if err != nil {
+		log.Fatalf("Error configuring OpenWeather: %v", err)
+	}
+
+	providers := weather.NewRegistry()
+	providers.Register(config.WeatherProviderOpenWeather, func() (weather.Provider, error) {
+		return openweather.New(cfg.OpenWeatherAPIKey, openWeatherOpts...), nil
+	})
+	providers.Register(config.WeatherProviderOpenWeatherSecondary, func() (weather.Provider, error) {
+		return openweather.New(cfg.OpenWeatherSecondaryAPIKey, openWeatherOpts...), nil
+	})
+	providers.Register(config.WeatherProviderOpenMeteo, func() (weather.Provider, error) {
+		// Open-Meteo is an alternative to OpenWeather, so it shares its
+		// User-Agent and request timeout.
+		opts := []openmeteo.Option{
+			openmeteo.WithUserAgent(cfg.OpenWeatherUserAgent),
+			openmeteo.WithTimeout(cfg.OpenWeatherTimeout),
+		}
+		if cfg.OpenMeteoBaseURL != "" {
+			opts = append(opts, openmeteo.WithBaseURLs(cfg.OpenMeteoBaseURL, cfg.OpenMeteoBaseURL, cfg.OpenMeteoBaseURL))
+		}
+		return openmeteo.New(opts...), nil
+	})
+
+	backends := make([]failover.Backend, 0, len(cfg.WeatherProviders))
+	for _, name := range cfg.WeatherProviders {
+		provider, err := providers.New(name)
+		if err != nil {
+			log.Fatalf("Error creating weather provider: %v", err)
+		}
+		backends = append(backends, failover.Backend{Name: name, Provider: provider})
+	}
+	log.Printf("Using weather providers %s", strings.Join(cfg.WeatherProviders, ", "))
+
+	failoverProvider := failover.New(backends, failover.Config{
+		FailureThreshold: cfg.FailoverThreshold,
+		Cooldown:         cfg.FailoverCooldown,
+		Timeout:          cfg.FailoverTimeout,
+	})
+	expvar.Publish("weather_providers", expvar.Func(func() any { return failoverProvider.Stats() }))
+
+	weatherClient := cache.New(failoverProvider, cfg.CoordinatesCacheTTL, cfg.WeatherCacheTTL)
+	expvar.Publish("weather_cache", expvar.Func(func() any { return weatherClient.Stats() }))
 
 	userRepo := repo.New(pool)
 
-	botHandler := handler.New(bot, owClient, userRepo)
+	botHandler := handler.New(bot, weatherClient, userRepo,
+		handler.WithLimits(handler.Limits{
+			PerMinute:   cfg.UserRateLimit,
+			Burst:       cfg.UserRateBurst,
+			MaxInFlight: cfg.MaxConcurrentUpdates,
+		}),
+		handler.WithAdmins(cfg.AdminIDs...),
+		handler.WithStats("weather_providers", func() any { return failoverProvider.Stats() }),
+		handler.WithStats("weather_cache", func() any { return weatherClient.Stats() }),
+	)
+	err = botHandler.PublishCommands()
+	if err != nil {
+		log.Printf("Error publishing bot commands: %v", err)
+	}
+
+	digestScheduler := scheduler.New(bot, userRepo, botHandler)
+
+	var wg sync.WaitGroup
+	if cfg.MetricsAddr != "" {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			serveMetrics(ctx, cfg.MetricsAddr)
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		digestScheduler.Start(ctx)
+	}()
+
+	if cfg.AlertCheckInterval > 0 {
+		alertEvaluator := alerts.New(bot, userRepo, weatherClient, botHandler, cfg.AlertCheckInterval)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			alertEvaluator.Start(ctx)
+		}()
+	}
+
+	switch cfg.UpdateMode {
+	case config.UpdateModeWebhook:
+		// setWebhook is called directly because the library's WebhookConfig
+		// has no secret_token.
+		_, err = bot.MakeRequest("setWebhook", tgbotapi.Params{
+			"url":          strings.TrimSuffix(cfg.WebhookURL, "/") + cfg.WebhookPath,
+			"secret_token": cfg.WebhookSecret,
+		})
+		if err != nil {
+			log.Fatalf("Error setting webhook: %v", err)
+		}
+
+		err = botHandler.StartWebhook(ctx, handler.WebhookConfig{
+			ListenAddr: cfg.WebhookListenAddr,
+			Path:       cfg.WebhookPath,
+			Secret:     cfg.WebhookSecret,
+		})
+		if err != nil {
+			log.Printf("Webhook server failed: %v", err)
+			stop()
+		}
+	default:
+		// Long polling doesn't work while a webhook is set.
+		_, err = bot.Request(tgbotapi.DeleteWebhookConfig{})
+		if err != nil {
+			log.Fatalf("Error deleting webhook: %v", err)
+		}
 
-	botHandler.Start(ctx)
+		botHandler.Start(ctx)
+	}
+
+	wg.Wait()
+}
+
+// openWeatherOptions configures the HTTP client, host and user agent of the
+// OpenWeather clients.
+func openWeatherOptions(cfg *config.Config) ([]openweather.Option, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.MaxIdleConnsPerHost = 10
+	if cfg.OpenWeatherProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.OpenWeatherProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	opts := []openweather.Option{
+		openweather.WithHTTPClient(&http.Client{Transport: transport}),
+		openweather.WithUserAgent(cfg.OpenWeatherUserAgent),
+		openweather.WithTimeout(cfg.OpenWeatherTimeout),
+	}
+	if cfg.OpenWeatherBaseURL != "" {
+		// Plain http is fine for a local mock, but the API key is in the
+		// query string.
+		if strings.HasPrefix(cfg.OpenWeatherBaseURL, "http://") {
+			log.Printf("OPEN_WEATHER_BASE_URL %s is plain http, the API key is sent unencrypted", cfg.OpenWeatherBaseURL)
+		}
+		opts = append(opts, openweather.WithBaseURL(cfg.OpenWeatherBaseURL))
+	}
+	return opts, nil
+}
+
+// serveMetrics exposes expvar counters at /debug/vars until ctx is cancelled.
+func serveMetrics(ctx context.Context, addr string) {
+	mux := http.NewServeMux()
+	mux.Handle("/debug/vars", expvar.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	log.Printf("Metrics are served on %s/debug/vars", addr)
+
+	err := server.ListenAndServe()
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("Metrics server failed: %v", err)
+	}
 }
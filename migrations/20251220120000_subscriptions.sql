@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE subscriptions (
+    user_id bigint primary key references users (id) on delete cascade,
+    chat_id bigint not null,
+    notify_at integer not null,
+    tz_offset integer not null,
+    next_run_at timestamptz not null,
+    created_at timestamp default NOW()
+);
+
+CREATE INDEX subscriptions_next_run_at_idx ON subscriptions (next_run_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE subscriptions;
+-- +goose StatementEnd
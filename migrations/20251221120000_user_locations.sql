@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE user_locations (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    name text not null,
+    city text not null,
+    is_default boolean not null default false,
+    created_at timestamp default NOW(),
+    unique (user_id, name)
+);
+
+CREATE UNIQUE INDEX user_locations_default_idx ON user_locations (user_id) WHERE is_default;
+
+INSERT INTO user_locations (user_id, name, city, is_default)
+SELECT id, 'home', city, true FROM users WHERE city IS NOT NULL AND city <> '';
+
+ALTER TABLE users DROP COLUMN city;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN city text;
+
+UPDATE users SET city = l.city
+FROM user_locations l
+WHERE l.user_id = users.id AND l.is_default;
+
+DROP TABLE user_locations;
+-- +goose StatementEnd
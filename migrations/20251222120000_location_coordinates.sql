@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE user_locations
+    ADD COLUMN country text not null default '',
+    ADD COLUMN state text not null default '',
+    ADD COLUMN lat double precision,
+    ADD COLUMN lon double precision;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE user_locations
+    DROP COLUMN country,
+    DROP COLUMN state,
+    DROP COLUMN lat,
+    DROP COLUMN lon;
+-- +goose StatementEnd
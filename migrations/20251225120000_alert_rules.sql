@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE alert_rules (
+    id bigserial primary key,
+    user_id bigint not null references users (id) on delete cascade,
+    chat_id bigint not null,
+    location_id bigint not null references user_locations (id) on delete cascade,
+    kind text not null,
+    threshold double precision not null,
+    created_at timestamp default NOW()
+);
+
+CREATE INDEX alert_rules_user_id_idx ON alert_rules (user_id);
+
+-- alert_events remembers the days a rule has already fired on, so a rule
+-- notifies once per day even with several replicas running.
+CREATE TABLE alert_events (
+    rule_id bigint not null references alert_rules (id) on delete cascade,
+    day date not null,
+    sent_at timestamp default NOW(),
+    primary key (rule_id, day)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE alert_events;
+DROP TABLE alert_rules;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE warning_subscriptions (
+    user_id bigint primary key references users (id) on delete cascade,
+    chat_id bigint not null,
+    created_at timestamp default NOW()
+);
+
+-- warning_events remembers the official warnings a user has been sent.
+CREATE TABLE warning_events (
+    user_id bigint not null references users (id) on delete cascade,
+    warning_key text not null,
+    ends_at timestamptz not null,
+    sent_at timestamp default NOW(),
+    primary key (user_id, warning_key)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE warning_events;
+DROP TABLE warning_subscriptions;
+-- +goose StatementEnd
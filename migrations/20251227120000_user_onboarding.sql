@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+    ADD COLUMN onboarded_at timestamptz;
+-- Users from before /start have already found their way around.
+UPDATE users SET onboarded_at = created_at;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+    DROP COLUMN onboarded_at;
+-- +goose StatementEnd
@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+-- dialogs remembers the question the bot asked a user in a chat, the next
+-- plain text message of the user is the answer.
+CREATE TABLE dialogs (
+    chat_id bigint not null,
+    user_id bigint not null references users (id) on delete cascade,
+    state text not null,
+    expires_at timestamptz not null,
+    primary key (chat_id, user_id)
+);
+
+CREATE INDEX dialogs_expires_at_idx ON dialogs (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE dialogs;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- city_choices keeps the cities offered with buttons when a city name is
+-- ambiguous, so the choice can be handled by any replica of the bot.
+CREATE TABLE city_choices (
+    chat_id bigint not null,
+    message_id bigint not null,
+    user_id bigint not null references users (id) on delete cascade,
+    location_name text not null,
+    cities jsonb not null,
+    expires_at timestamptz not null,
+    primary key (chat_id, message_id)
+);
+
+CREATE INDEX city_choices_expires_at_idx ON city_choices (expires_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE city_choices;
+-- +goose StatementEnd
@@ -4,6 +4,86 @@ import "time"
 
 type User struct {
 	ID        int64
+	Language  string // code of the language of the bot replies
+	Units     string // unit system of the weather, see weather.Units
+	CreatedAt time.Time
+	// OnboardedAt is when the user finished the /start questions, zero
+	// before that.
+	OnboardedAt time.Time
+}
+
+// DefaultLocationName is the name given to the city saved with /city.
+const DefaultLocationName = "home"
+
+// Location is a named city saved by a user, e.g. "home" or "office". Exactly
+// one location of a user with saved locations is the default one.
+type Location struct {
+	ID        int64
+	UserID    int64
+	Name      string
 	City      string
+	Country   string
+	State     string
+	Lat       float64
+	Lon       float64
+	Geocoded  bool // false for locations saved before coordinates were stored
+	IsDefault bool
+	CreatedAt time.Time
+}
+
+type Subscription struct {
+	UserID    int64
+	ChatID    int64
+	NotifyAt  int // minutes after local midnight
+	TZOffset  int // seconds east of UTC of the user's city
+	NextRunAt time.Time
+	CreatedAt time.Time
+}
+
+// Kinds of alert rules.
+const (
+	AlertCold = "cold" // tomorrow's minimum temperature below the threshold
+	AlertRain = "rain" // precipitation probability above the threshold soon
+	AlertWind = "wind" // wind gusts stronger than the threshold
+)
+
+// AlertRule notifies a user when the forecast for one of the user's
+// locations crosses a threshold.
+type AlertRule struct {
+	ID        int64
+	UserID    int64
+	ChatID    int64
+	Kind      string
+	Threshold float64  // °C, m/s or percent regardless of the user's units
+	Location  Location // location the forecast is checked for
+	CreatedAt time.Time
+}
+
+// WarningSubscription asks for official severe weather warnings issued for
+// the user's default location to be pushed to the chat.
+type WarningSubscription struct {
+	UserID    int64
+	ChatID    int64
+	Location  Location // default location of the user
 	CreatedAt time.Time
 }
+
+// Dialog is a question the bot asked a user in a chat, the next plain text
+// message of the user in the chat answers it.
+type Dialog struct {
+	ChatID    int64
+	UserID    int64
+	State     string // what the bot is waiting for, e.g. a city
+	ExpiresAt time.Time
+}
+
+// CityChoice is a choice between cities sharing a name offered to a user
+// with buttons under a message.
+type CityChoice struct {
+	ChatID       int64
+	MessageID    int
+	UserID       int64
+	LocationName string     // empty for the default location
+	Cities       []Location // the offered cities, in the order of the buttons
+	ExpiresAt    time.Time
+}
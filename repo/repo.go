@@ -2,9 +2,11 @@ package repo
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"study/weatherbot/models"
+	"time"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -20,27 +22,51 @@ func New(db *pgxpool.Pool) *Repo {
 	}
 }
 
-func (r *Repo) GetUserCity(ctx context.Context, userID int64) (string, error) {
-	var city string
-	row := r.db.QueryRow(ctx, "select city from users where id = $1", userID)
-	err := row.Scan(&city)
+func (r *Repo) CreateUser(ctx context.Context, userID int64, language string) error {
+	_, err := r.db.Exec(ctx, "insert into users (id, language) values ($1, $2)", userID, language)
 	if err != nil {
-		return "", fmt.Errorf("error row.Scan: %w", err)
+		return fmt.Errorf("error db.Exec: %w", err)
 	}
-
-	return city, nil
+	return nil
 }
 
-func (r *Repo) CreateUser(ctx context.Context, userID int64) error {
-	_, err := r.db.Exec(ctx, "insert into users (id) values ($1)", userID)
+func (r *Repo) UpdateUserLanguage(ctx context.Context, userID int64, language string) error {
+	_, err := r.db.Exec(ctx, "update users set language = $1 where id = $2", language, userID)
 	if err != nil {
 		return fmt.Errorf("error db.Exec: %w", err)
 	}
 	return nil
 }
 
-func (r *Repo) UpdateUserCity(ctx context.Context, userID int64, city string) error {
-	_, err := r.db.Exec(ctx, "update users set city = $1 where id = $2", city, userID)
+// UpdateUserCity moves the user's default location to the given place and
+// returns its name. A user without saved locations gets it saved as the
+// default "home" location.
+func (r *Repo) UpdateUserCity(ctx context.Context, place models.Location) (string, error) {
+	var name string
+	row := r.db.QueryRow(ctx, `
+		update user_locations
+		set city = $1, country = $2, state = $3, lat = $4, lon = $5
+		where user_id = $6 and is_default
+		returning name`,
+		place.City, place.Country, place.State, place.Lat, place.Lon, place.UserID)
+	err := row.Scan(&name)
+	if err == nil {
+		return name, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("error row.Scan: %w", err)
+	}
+
+	place.Name = models.DefaultLocationName
+	err = r.AddLocation(ctx, place)
+	if err != nil {
+		return "", err
+	}
+	return place.Name, nil
+}
+
+func (r *Repo) UpdateUserUnits(ctx context.Context, userID int64, units string) error {
+	_, err := r.db.Exec(ctx, "update users set units = $1 where id = $2", units, userID)
 	if err != nil {
 		return fmt.Errorf("error db.Exec: %w", err)
 	}
@@ -49,8 +75,9 @@ func (r *Repo) UpdateUserCity(ctx context.Context, userID int64, city string) er
 
 func (r *Repo) GetUser(ctx context.Context, userID int64) (*models.User, error) {
 	user := models.User{}
-	row := r.db.QueryRow(ctx, "select id, city, created_at from users where id = $1", userID)
-	err := row.Scan(&user.ID, &user.City, &user.CreatedAt)
+	var onboardedAt *time.Time
+	row := r.db.QueryRow(ctx, "select id, language, units, created_at, onboarded_at from users where id = $1", userID)
+	err := row.Scan(&user.ID, &user.Language, &user.Units, &user.CreatedAt, &onboardedAt)
 
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -58,6 +85,517 @@ func (r *Repo) GetUser(ctx context.Context, userID int64) (*models.User, error)
 		}
 		return &models.User{}, fmt.Errorf("error row.Scan: %w", err)
 	}
+	if onboardedAt != nil {
+		user.OnboardedAt = *onboardedAt
+	}
 
 	return &user, nil
 }
+
+// CompleteOnboarding records that the user finished the /start questions.
+func (r *Repo) CompleteOnboarding(ctx context.Context, userID int64) error {
+	_, err := r.db.Exec(ctx, "update users set onboarded_at = coalesce(onboarded_at, now()) where id = $1", userID)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+const locationColumns = "id, user_id, name, city, country, state, lat, lon, is_default, created_at"
+
+func scanLocation(row pgx.Row) (models.Location, error) {
+	var location models.Location
+	var lat, lon *float64
+	err := row.Scan(
+		&location.ID, &location.UserID, &location.Name,
+		&location.City, &location.Country, &location.State, &lat, &lon,
+		&location.IsDefault, &location.CreatedAt,
+	)
+	if lat != nil && lon != nil {
+		location.Lat, location.Lon, location.Geocoded = *lat, *lon, true
+	}
+	return location, err
+}
+
+func (r *Repo) getLocation(ctx context.Context, query string, args ...any) (*models.Location, error) {
+	location, err := scanLocation(r.db.QueryRow(ctx, query, args...))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error row.Scan: %w", err)
+	}
+
+	return &location, nil
+}
+
+func (r *Repo) GetDefaultLocation(ctx context.Context, userID int64) (*models.Location, error) {
+	return r.getLocation(ctx, "select "+locationColumns+" from user_locations where user_id = $1 and is_default", userID)
+}
+
+func (r *Repo) GetLocation(ctx context.Context, userID int64, name string) (*models.Location, error) {
+	return r.getLocation(ctx, "select "+locationColumns+" from user_locations where user_id = $1 and name = $2", userID, name)
+}
+
+func (r *Repo) ListLocations(ctx context.Context, userID int64) ([]models.Location, error) {
+	rows, err := r.db.Query(ctx, "select "+locationColumns+" from user_locations where user_id = $1 order by is_default desc, name", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error db.Query: %w", err)
+	}
+
+	locations, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Location, error) {
+		return scanLocation(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pgx.CollectRows: %w", err)
+	}
+
+	return locations, nil
+}
+
+// AddLocation saves a named location or moves an existing one to another
+// place. The first location of a user becomes the default.
+func (r *Repo) AddLocation(ctx context.Context, location models.Location) error {
+	_, err := r.db.Exec(ctx, `
+		insert into user_locations (user_id, name, city, country, state, lat, lon, is_default)
+		values ($1, $2, $3, $4, $5, $6, $7, not exists (select 1 from user_locations where user_id = $1 and is_default))
+		on conflict (user_id, name) do update
+		set city = excluded.city,
+			country = excluded.country,
+			state = excluded.state,
+			lat = excluded.lat,
+			lon = excluded.lon`,
+		location.UserID, location.Name, location.City, location.Country, location.State, location.Lat, location.Lon)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// DeleteLocation removes a named location. When the default location is
+// removed the oldest remaining one takes its place.
+func (r *Repo) DeleteLocation(ctx context.Context, userID int64, name string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var wasDefault bool
+	row := tx.QueryRow(ctx, "delete from user_locations where user_id = $1 and name = $2 returning is_default", userID, name)
+	err = row.Scan(&wasDefault)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return false, nil
+		}
+		return false, fmt.Errorf("error row.Scan: %w", err)
+	}
+
+	if wasDefault {
+		_, err = tx.Exec(ctx, `
+			update user_locations set is_default = true
+			where id = (select id from user_locations where user_id = $1 order by created_at, id limit 1)`,
+			userID)
+		if err != nil {
+			return false, fmt.Errorf("error tx.Exec: %w", err)
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error tx.Commit: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *Repo) SetDefaultLocation(ctx context.Context, userID int64, name string) (bool, error) {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error db.Begin: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The partial unique index allows a single default per user, so the old
+	// one has to be cleared before the new one is set.
+	_, err = tx.Exec(ctx, "update user_locations set is_default = false where user_id = $1 and is_default and name <> $2", userID, name)
+	if err != nil {
+		return false, fmt.Errorf("error tx.Exec: %w", err)
+	}
+
+	tag, err := tx.Exec(ctx, "update user_locations set is_default = true where user_id = $1 and name = $2", userID, name)
+	if err != nil {
+		return false, fmt.Errorf("error tx.Exec: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return false, nil
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		return false, fmt.Errorf("error tx.Commit: %w", err)
+	}
+
+	return true, nil
+}
+
+func (r *Repo) UpsertSubscription(ctx context.Context, sub models.Subscription) error {
+	_, err := r.db.Exec(ctx, `
+		insert into subscriptions (user_id, chat_id, notify_at, tz_offset, next_run_at)
+		values ($1, $2, $3, $4, $5)
+		on conflict (user_id) do update
+		set chat_id = excluded.chat_id,
+			notify_at = excluded.notify_at,
+			tz_offset = excluded.tz_offset,
+			next_run_at = excluded.next_run_at`,
+		sub.UserID, sub.ChatID, sub.NotifyAt, sub.TZOffset, sub.NextRunAt)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) DeleteSubscription(ctx context.Context, userID int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, "delete from subscriptions where user_id = $1", userID)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// ClaimDueSubscriptions atomically moves up to limit subscriptions that are
+// due at now to their next daily run and returns them. Rows locked by another
+// replica are skipped, so every run is claimed by exactly one process.
+func (r *Repo) ClaimDueSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	rows, err := r.db.Query(ctx, `
+		update subscriptions
+		set next_run_at = next_run_at + (floor(extract(epoch from $1::timestamptz - next_run_at) / 86400) + 1) * interval '1 day'
+		where user_id in (
+			select user_id from subscriptions
+			where next_run_at <= $1::timestamptz
+			order by next_run_at
+			limit $2
+			for update skip locked
+		)
+		returning user_id, chat_id, notify_at, tz_offset, next_run_at, created_at`,
+		now, limit)
+	if err != nil {
+		return nil, fmt.Errorf("error db.Query: %w", err)
+	}
+
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.Subscription, error) {
+		var sub models.Subscription
+		err := row.Scan(&sub.UserID, &sub.ChatID, &sub.NotifyAt, &sub.TZOffset, &sub.NextRunAt, &sub.CreatedAt)
+		return sub, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pgx.CollectRows: %w", err)
+	}
+
+	return subs, nil
+}
+
+func (r *Repo) UpdateSubscriptionSchedule(ctx context.Context, userID int64, tzOffset int, nextRunAt time.Time) error {
+	_, err := r.db.Exec(ctx, "update subscriptions set tz_offset = $1, next_run_at = $2 where user_id = $3", tzOffset, nextRunAt, userID)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+const alertRuleColumns = `r.id, r.user_id, r.chat_id, r.kind, r.threshold, r.created_at,
+	l.id, l.user_id, l.name, l.city, l.country, l.state, l.lat, l.lon, l.is_default, l.created_at`
+
+func scanAlertRule(row pgx.Row) (models.AlertRule, error) {
+	var rule models.AlertRule
+	var lat, lon *float64
+	location := &rule.Location
+	err := row.Scan(
+		&rule.ID, &rule.UserID, &rule.ChatID, &rule.Kind, &rule.Threshold, &rule.CreatedAt,
+		&location.ID, &location.UserID, &location.Name,
+		&location.City, &location.Country, &location.State, &lat, &lon,
+		&location.IsDefault, &location.CreatedAt,
+	)
+	if lat != nil && lon != nil {
+		location.Lat, location.Lon, location.Geocoded = *lat, *lon, true
+	}
+	return rule, err
+}
+
+func (r *Repo) listAlertRules(ctx context.Context, query string, args ...any) ([]models.AlertRule, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error db.Query: %w", err)
+	}
+
+	rules, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.AlertRule, error) {
+		return scanAlertRule(row)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pgx.CollectRows: %w", err)
+	}
+
+	return rules, nil
+}
+
+// AddAlertRule saves the rule for rule.Location and returns its ID.
+func (r *Repo) AddAlertRule(ctx context.Context, rule models.AlertRule) (int64, error) {
+	var id int64
+	err := r.db.QueryRow(ctx, `
+		insert into alert_rules (user_id, chat_id, location_id, kind, threshold)
+		values ($1, $2, $3, $4, $5)
+		returning id`,
+		rule.UserID, rule.ChatID, rule.Location.ID, rule.Kind, rule.Threshold).Scan(&id)
+	if err != nil {
+		return 0, fmt.Errorf("error row.Scan: %w", err)
+	}
+	return id, nil
+}
+
+func (r *Repo) ListAlertRules(ctx context.Context, userID int64) ([]models.AlertRule, error) {
+	return r.listAlertRules(ctx, `
+		select `+alertRuleColumns+`
+		from alert_rules r join user_locations l on l.id = r.location_id
+		where r.user_id = $1
+		order by r.id`, userID)
+}
+
+// AllAlertRules returns the rules of all users for the evaluator.
+func (r *Repo) AllAlertRules(ctx context.Context) ([]models.AlertRule, error) {
+	return r.listAlertRules(ctx, `
+		select `+alertRuleColumns+`
+		from alert_rules r join user_locations l on l.id = r.location_id
+		order by r.location_id, r.id`)
+}
+
+func (r *Repo) DeleteAlertRule(ctx context.Context, userID int64, ruleID int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, "delete from alert_rules where id = $1 and user_id = $2", ruleID, userID)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// RecordAlertEvent marks the rule as fired on the given local day. It reports
+// false when the rule has already fired that day, possibly on another
+// replica, and the user must not be notified again.
+func (r *Repo) RecordAlertEvent(ctx context.Context, ruleID int64, day time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		insert into alert_events (rule_id, day) values ($1, $2::date)
+		on conflict (rule_id, day) do nothing`,
+		ruleID, day.Format(time.DateOnly))
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteAlertEvent forgets a recorded event so the rule can fire again, e.g.
+// when the notification could not be sent.
+func (r *Repo) DeleteAlertEvent(ctx context.Context, ruleID int64, day time.Time) error {
+	_, err := r.db.Exec(ctx, "delete from alert_events where rule_id = $1 and day = $2::date", ruleID, day.Format(time.DateOnly))
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// DeleteAlertEventsBefore removes events of past days that can't fire again.
+func (r *Repo) DeleteAlertEventsBefore(ctx context.Context, day time.Time) error {
+	_, err := r.db.Exec(ctx, "delete from alert_events where day < $1::date", day.Format(time.DateOnly))
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) EnableWarnings(ctx context.Context, userID int64, chatID int64) error {
+	_, err := r.db.Exec(ctx, `
+		insert into warning_subscriptions (user_id, chat_id) values ($1, $2)
+		on conflict (user_id) do update set chat_id = excluded.chat_id`,
+		userID, chatID)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+func (r *Repo) DisableWarnings(ctx context.Context, userID int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, "delete from warning_subscriptions where user_id = $1", userID)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// WarningSubscriptions returns the subscribed users together with their
+// default locations. Users without a location are skipped.
+func (r *Repo) WarningSubscriptions(ctx context.Context) ([]models.WarningSubscription, error) {
+	rows, err := r.db.Query(ctx, `
+		select s.user_id, s.chat_id, s.created_at,
+			l.id, l.user_id, l.name, l.city, l.country, l.state, l.lat, l.lon, l.is_default, l.created_at
+		from warning_subscriptions s join user_locations l on l.user_id = s.user_id and l.is_default
+		order by l.id`)
+	if err != nil {
+		return nil, fmt.Errorf("error db.Query: %w", err)
+	}
+
+	subs, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (models.WarningSubscription, error) {
+		var sub models.WarningSubscription
+		var lat, lon *float64
+		location := &sub.Location
+		err := row.Scan(
+			&sub.UserID, &sub.ChatID, &sub.CreatedAt,
+			&location.ID, &location.UserID, &location.Name,
+			&location.City, &location.Country, &location.State, &lat, &lon,
+			&location.IsDefault, &location.CreatedAt,
+		)
+		if lat != nil && lon != nil {
+			location.Lat, location.Lon, location.Geocoded = *lat, *lon, true
+		}
+		return sub, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error pgx.CollectRows: %w", err)
+	}
+
+	return subs, nil
+}
+
+// RecordWarning marks the warning as sent to the user. It reports false when
+// it has already been sent, possibly by another replica.
+func (r *Repo) RecordWarning(ctx context.Context, userID int64, key string, endsAt time.Time) (bool, error) {
+	tag, err := r.db.Exec(ctx, `
+		insert into warning_events (user_id, warning_key, ends_at) values ($1, $2, $3)
+		on conflict (user_id, warning_key) do nothing`,
+		userID, key, endsAt)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// DeleteWarning forgets a recorded warning so it is sent again.
+func (r *Repo) DeleteWarning(ctx context.Context, userID int64, key string) error {
+	_, err := r.db.Exec(ctx, "delete from warning_events where user_id = $1 and warning_key = $2", userID, key)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// DeleteWarningsEndedBefore removes warnings that are no longer issued.
+func (r *Repo) DeleteWarningsEndedBefore(ctx context.Context, t time.Time) error {
+	_, err := r.db.Exec(ctx, "delete from warning_events where ends_at < $1", t)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// SetDialog starts or replaces the dialog of the user in the chat. Expired
+// dialogs of all users are removed on the way.
+func (r *Repo) SetDialog(ctx context.Context, dialog models.Dialog) error {
+	_, err := r.db.Exec(ctx, "delete from dialogs where expires_at < now()")
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		insert into dialogs (chat_id, user_id, state, expires_at) values ($1, $2, $3, $4)
+		on conflict (chat_id, user_id) do update set state = excluded.state, expires_at = excluded.expires_at`,
+		dialog.ChatID, dialog.UserID, dialog.State, dialog.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// GetDialog returns the unexpired dialog of the user in the chat, nil if
+// there is none.
+func (r *Repo) GetDialog(ctx context.Context, chatID int64, userID int64) (*models.Dialog, error) {
+	dialog := models.Dialog{}
+	row := r.db.QueryRow(ctx, `
+		select chat_id, user_id, state, expires_at from dialogs
+		where chat_id = $1 and user_id = $2 and expires_at > now()`,
+		chatID, userID)
+	err := row.Scan(&dialog.ChatID, &dialog.UserID, &dialog.State, &dialog.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error row.Scan: %w", err)
+	}
+	return &dialog, nil
+}
+
+// DeleteDialog ends the dialog of the user in the chat. It reports whether
+// there was an unexpired one.
+func (r *Repo) DeleteDialog(ctx context.Context, chatID int64, userID int64) (bool, error) {
+	tag, err := r.db.Exec(ctx, "delete from dialogs where chat_id = $1 and user_id = $2 and expires_at > now()", chatID, userID)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+// SaveCityChoice stores the cities offered under the message. Expired
+// choices of all users are removed on the way.
+func (r *Repo) SaveCityChoice(ctx context.Context, choice models.CityChoice) error {
+	cities, err := json.Marshal(choice.Cities)
+	if err != nil {
+		return fmt.Errorf("error json.Marshal: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, "delete from city_choices where expires_at < now()")
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+
+	_, err = r.db.Exec(ctx, `
+		insert into city_choices (chat_id, message_id, user_id, location_name, cities, expires_at)
+		values ($1, $2, $3, $4, $5, $6)
+		on conflict (chat_id, message_id) do update set
+			user_id = excluded.user_id, location_name = excluded.location_name,
+			cities = excluded.cities, expires_at = excluded.expires_at`,
+		choice.ChatID, choice.MessageID, choice.UserID, choice.LocationName, cities, choice.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("error db.Exec: %w", err)
+	}
+	return nil
+}
+
+// GetCityChoice returns the unexpired choice offered under the message, nil
+// if there is none.
+func (r *Repo) GetCityChoice(ctx context.Context, chatID int64, messageID int) (*models.CityChoice, error) {
+	choice := models.CityChoice{}
+	var cities []byte
+	row := r.db.QueryRow(ctx, `
+		select chat_id, message_id, user_id, location_name, cities, expires_at from city_choices
+		where chat_id = $1 and message_id = $2 and expires_at > now()`,
+		chatID, messageID)
+	err := row.Scan(&choice.ChatID, &choice.MessageID, &choice.UserID, &choice.LocationName, &cities, &choice.ExpiresAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error row.Scan: %w", err)
+	}
+
+	err = json.Unmarshal(cities, &choice.Cities)
+	if err != nil {
+		return nil, fmt.Errorf("error json.Unmarshal: %w", err)
+	}
+	return &choice, nil
+}
+
+// DeleteCityChoice removes the choice offered under the message. It reports
+// false when the choice has expired or was already made, possibly on
+// another replica.
+func (r *Repo) DeleteCityChoice(ctx context.Context, chatID int64, messageID int) (bool, error) {
+	tag, err := r.db.Exec(ctx, "delete from city_choices where chat_id = $1 and message_id = $2 and expires_at > now()", chatID, messageID)
+	if err != nil {
+		return false, fmt.Errorf("error db.Exec: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,137 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"study/weatherbot/models"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+const (
+	tickInterval = time.Minute
+	claimLimit   = 50
+	sendTimeout  = 15 * time.Second
+)
+
+type subscriptionRepository interface {
+	ClaimDueSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error)
+	UpdateSubscriptionSchedule(ctx context.Context, userID int64, tzOffset int, nextRunAt time.Time) error
+}
+
+// digester builds the digest text for a user together with the current UTC
+// offset of the user's city.
+type digester interface {
+	Digest(ctx context.Context, userID int64) (string, int, error)
+}
+
+type botAPI interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+}
+
+type Scheduler struct {
+	bot      botAPI
+	subsRepo subscriptionRepository
+	digester digester
+	now      func() time.Time
+}
+
+func New(bot botAPI, subsRepo subscriptionRepository, digester digester) *Scheduler {
+	return &Scheduler{
+		bot:      bot,
+		subsRepo: subsRepo,
+		digester: digester,
+		now:      time.Now,
+	}
+}
+
+// Start delivers due digests once a minute until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+
+	log.Println("Scheduler started...")
+
+	for {
+		s.runDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			log.Println("Scheduler stopped gracefully.")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue claims and sends due digests batch by batch. Claiming moves a
+// subscription to its next run before the digest is sent, so a failed send
+// skips that day instead of being retried by another replica.
+func (s *Scheduler) runDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		subs, err := s.subsRepo.ClaimDueSubscriptions(ctx, s.now(), claimLimit)
+		if err != nil {
+			log.Println("error subsRepo.ClaimDueSubscriptions: ", err)
+			return
+		}
+
+		// Claimed digests are delivered even if shutdown starts meanwhile,
+		// otherwise they would be lost until the next day.
+		var wg sync.WaitGroup
+		for _, sub := range subs {
+			wg.Add(1)
+			go func(sub models.Subscription) {
+				defer wg.Done()
+				sendCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), sendTimeout)
+				defer cancel()
+				s.send(sendCtx, sub)
+			}(sub)
+		}
+		wg.Wait()
+
+		if len(subs) < claimLimit {
+			return
+		}
+	}
+}
+
+func (s *Scheduler) send(ctx context.Context, sub models.Subscription) {
+	text, tzOffset, err := s.digester.Digest(ctx, sub.UserID)
+	if err != nil {
+		log.Printf("error digester.Digest for user %d: %v", sub.UserID, err)
+		return
+	}
+
+	_, err = s.bot.Send(tgbotapi.NewMessage(sub.ChatID, text))
+	if err != nil {
+		log.Printf("error bot.Send digest for user %d: %v", sub.UserID, err)
+	}
+
+	// The city's offset changes with DST or when the user moves to another
+	// city, keep the delivery time pinned to the local clock.
+	if tzOffset != sub.TZOffset {
+		next := NextRun(s.now(), sub.NotifyAt, tzOffset)
+		err = s.subsRepo.UpdateSubscriptionSchedule(ctx, sub.UserID, tzOffset, next)
+		if err != nil {
+			log.Printf("error subsRepo.UpdateSubscriptionSchedule for user %d: %v", sub.UserID, err)
+		}
+	}
+}
+
+// NextRun returns the first moment strictly after now at which the local
+// clock of a zone with the given UTC offset shows notifyAt minutes past
+// midnight.
+func NextRun(now time.Time, notifyAt int, tzOffset int) time.Time {
+	loc := time.FixedZone("", tzOffset)
+	local := now.In(loc)
+
+	next := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc).
+		Add(time.Duration(notifyAt) * time.Minute)
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+
+	return next.UTC()
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"study/weatherbot/models"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+type mockSubsRepo struct {
+	due     []models.Subscription
+	err     error
+	updated map[int64]time.Time
+}
+
+func (m *mockSubsRepo) ClaimDueSubscriptions(ctx context.Context, now time.Time, limit int) ([]models.Subscription, error) {
+	due := m.due
+	m.due = nil
+	return due, m.err
+}
+func (m *mockSubsRepo) UpdateSubscriptionSchedule(ctx context.Context, userID int64, tzOffset int, nextRunAt time.Time) error {
+	m.updated[userID] = nextRunAt
+	return nil
+}
+
+type mockDigester struct {
+	tzOffset int
+	err      map[int64]error
+}
+
+func (m *mockDigester) Digest(ctx context.Context, userID int64) (string, int, error) {
+	return "digest", m.tzOffset, m.err[userID]
+}
+
+type mockBotAPI struct {
+	sent chan tgbotapi.Chattable
+}
+
+func (m *mockBotAPI) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	m.sent <- c
+	return tgbotapi.Message{}, nil
+}
+
+func TestNextRun(t *testing.T) {
+	msk := 3 * 60 * 60
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		notifyAt int
+		tzOffset int
+		want     time.Time
+	}{
+		{"Later today", time.Date(2025, 12, 15, 4, 0, 0, 0, time.UTC), 8 * 60, msk, time.Date(2025, 12, 15, 5, 0, 0, 0, time.UTC)},
+		{"Already passed", time.Date(2025, 12, 15, 6, 0, 0, 0, time.UTC), 8 * 60, msk, time.Date(2025, 12, 16, 5, 0, 0, 0, time.UTC)},
+		{"Exactly now", time.Date(2025, 12, 15, 5, 0, 0, 0, time.UTC), 8 * 60, msk, time.Date(2025, 12, 16, 5, 0, 0, 0, time.UTC)},
+		{"Local date ahead of UTC", time.Date(2025, 12, 15, 22, 0, 0, 0, time.UTC), 7*60 + 30, msk, time.Date(2025, 12, 16, 4, 30, 0, 0, time.UTC)},
+		{"Negative offset", time.Date(2025, 12, 15, 10, 0, 0, 0, time.UTC), 9 * 60, -5 * 60 * 60, time.Date(2025, 12, 15, 14, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := NextRun(tt.now, tt.notifyAt, tt.tzOffset)
+			if !got.Equal(tt.want) {
+				t.Errorf("NextRun() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScheduler_RunDue(t *testing.T) {
+	now := time.Date(2025, 12, 15, 5, 0, 0, 0, time.UTC)
+	repo := &mockSubsRepo{
+		due: []models.Subscription{
+			{UserID: 1, ChatID: 10, NotifyAt: 8 * 60, TZOffset: 3 * 60 * 60},
+			{UserID: 2, ChatID: 20, NotifyAt: 8 * 60, TZOffset: 2 * 60 * 60},
+			{UserID: 3, ChatID: 30, NotifyAt: 8 * 60, TZOffset: 3 * 60 * 60},
+		},
+		updated: map[int64]time.Time{},
+	}
+	digester := &mockDigester{
+		tzOffset: 3 * 60 * 60,
+		err:      map[int64]error{3: errors.New("no city")},
+	}
+	bot := &mockBotAPI{sent: make(chan tgbotapi.Chattable, 3)}
+
+	s := New(bot, repo, digester)
+	s.now = func() time.Time { return now }
+
+	s.runDue(context.Background())
+	close(bot.sent)
+
+	chats := map[int64]bool{}
+	for c := range bot.sent {
+		chats[c.(tgbotapi.MessageConfig).ChatID] = true
+	}
+	if len(chats) != 2 || !chats[10] || !chats[20] {
+		t.Errorf("got digests sent to %v, want chats 10 and 20", chats)
+	}
+
+	// Only the subscription whose offset changed is rescheduled.
+	if len(repo.updated) != 1 {
+		t.Fatalf("got %d rescheduled subscriptions, want 1", len(repo.updated))
+	}
+	if want := time.Date(2025, 12, 16, 5, 0, 0, 0, time.UTC); !repo.updated[2].Equal(want) {
+		t.Errorf("got next run %v, want %v", repo.updated[2], want)
+	}
+}
@@ -0,0 +1,49 @@
+package weather
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Factory creates a provider. Only the factory of the configured provider is
+// called, so the others may lack their credentials.
+type Factory func() (Provider, error)
+
+// Registry maps provider names to their factories.
+type Registry struct {
+	factories map[string]Factory
+}
+
+func NewRegistry() *Registry {
+	return &Registry{factories: make(map[string]Factory)}
+}
+
+// Register adds a provider under name, replacing the one registered before.
+func (r *Registry) Register(name string, factory Factory) {
+	r.factories[name] = factory
+}
+
+// New creates the provider registered under name.
+func (r *Registry) New(name string) (Provider, error) {
+	factory, ok := r.factories[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown weather provider %q, want one of %s", name, strings.Join(r.Names(), ", "))
+	}
+
+	provider, err := factory()
+	if err != nil {
+		return nil, fmt.Errorf("error creating weather provider %q: %w", name, err)
+	}
+	return provider, nil
+}
+
+// Names returns the registered provider names in alphabetical order.
+func (r *Registry) Names() []string {
+	names := make([]string, 0, len(r.factories))
+	for name := range r.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
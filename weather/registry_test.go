@@ -0,0 +1,47 @@
+package weather
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type stubProvider struct {
+	Provider
+	name string
+}
+
+func (s stubProvider) Cities(ctx context.Context, city string) ([]Coordinate, error) {
+	return []Coordinate{{Name: s.name}}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	registry := NewRegistry()
+	registry.Register("openweather", func() (Provider, error) {
+		return nil, errors.New("API key is required")
+	})
+	registry.Register("open-meteo", func() (Provider, error) {
+		return stubProvider{name: "open-meteo"}, nil
+	})
+
+	if got := registry.Names(); !reflect.DeepEqual(got, []string{"open-meteo", "openweather"}) {
+		t.Errorf("got names %v", got)
+	}
+
+	provider, err := registry.New("open-meteo")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	cities, _ := provider.Cities(context.Background(), "Moscow")
+	if cities[0].Name != "open-meteo" {
+		t.Errorf("got provider %q, want open-meteo", cities[0].Name)
+	}
+
+	if _, err := registry.New("openweather"); err == nil {
+		t.Error("got no error from a failing factory")
+	}
+	if _, err := registry.New("yandex"); err == nil {
+		t.Error("got no error for an unknown provider")
+	}
+}
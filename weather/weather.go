@@ -0,0 +1,180 @@
+// Package weather defines the data the bot needs from a weather service
+// independently of the service, and the registry backends are chosen from.
+package weather
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+var (
+	ErrCityNotFound = errors.New("city not found")
+	// ErrNotSupported is returned for data the provider doesn't offer.
+	ErrNotSupported = errors.New("not supported by the weather provider")
+
+	// ErrUnauthorized, ErrRateLimited and ErrUpstream classify the failures
+	// of the provider's API, test for them with errors.Is.
+	ErrUnauthorized = errors.New("weather provider rejected the API key")
+	ErrRateLimited  = errors.New("weather provider rate limit exceeded")
+	ErrUpstream     = errors.New("weather provider is unavailable")
+)
+
+// StatusError is an unexpected HTTP status returned by the provider's API.
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // from the Retry-After header, zero when absent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error unexpected status: %d", e.StatusCode)
+}
+
+// Is reports the class of the status, see ErrUnauthorized.
+func (e *StatusError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrUpstream:
+		return e.StatusCode >= http.StatusInternalServerError
+	default:
+		return false
+	}
+}
+
+// Provider is a weather service backend.
+type Provider interface {
+	Coordinates(ctx context.Context, city string) (Coordinate, error)
+	Cities(ctx context.Context, city string) ([]Coordinate, error)
+	ReverseGeocode(ctx context.Context, lat float64, lon float64) (Coordinate, error)
+	Weather(ctx context.Context, lat float64, lon float64, params Params) (Weather, error)
+	Forecast(ctx context.Context, lat float64, lon float64, params Params) (Forecast, error)
+	Alerts(ctx context.Context, lat float64, lon float64) ([]Alert, error)
+	AirPollution(ctx context.Context, lat float64, lon float64) (AirPollution, error)
+	Nowcast(ctx context.Context, lat float64, lon float64) (Nowcast, error)
+}
+
+// Units is the unit system of the returned measurements.
+type Units string
+
+const (
+	Metric   Units = "metric"   // °C, m/s
+	Imperial Units = "imperial" // °F, mph
+	Standard Units = "standard" // K, m/s
+)
+
+// Params are the preferences of the user the weather is requested for.
+type Params struct {
+	Lang  string // language of condition descriptions, English when empty
+	Units Units  // Metric when empty
+}
+
+type Coordinate struct {
+	Name    string
+	Country string
+	State   string
+	Lat     float64
+	Lon     float64
+}
+
+type Weather struct {
+	City        string
+	Country     string
+	Time        time.Time // time of data calculation, in the location's zone
+	Temp        float64
+	FeelsLike   float64
+	TempMin     float64
+	TempMax     float64
+	Pressure    int // hPa
+	Humidity    int // %
+	WindSpeed   float64
+	WindDeg     int // meteorological degrees, 0 is north
+	WindGust    float64
+	Clouds      int     // %
+	Visibility  int     // meters, 10000 at most
+	Rain1h      float64 // mm
+	Snow1h      float64 // mm
+	ConditionID int     // provider specific condition code
+	Condition   string  // group of parameters: Rain, Snow, Clouds etc.
+	Description string
+	Icon        string
+	Sunrise     time.Time
+	Sunset      time.Time
+	Timezone    int // shift in seconds from UTC
+}
+
+type Forecast struct {
+	City     string
+	Timezone int           // shift in seconds from UTC
+	Step     time.Duration // length of a slot in Hours
+	Hours    []ForecastHour
+	Days     []DailyForecast
+}
+
+type ForecastHour struct {
+	Time        time.Time
+	Temp        float64
+	TempMin     float64
+	TempMax     float64
+	Pop         float64 // probability of precipitation, 0..1
+	WindSpeed   float64
+	WindGust    float64
+	ConditionID int
+	Description string
+}
+
+type DailyForecast struct {
+	Date        time.Time // local midnight of the day
+	TempMin     float64
+	TempMax     float64
+	Pop         float64
+	ConditionID int
+	Description string
+}
+
+// Alert is an official severe weather warning, e.g. a storm warning of the
+// national weather service.
+type Alert struct {
+	Sender      string
+	Event       string
+	Start       time.Time
+	End         time.Time
+	Description string
+}
+
+// Nowcast is the precipitation expected at a point in the next hours.
+type Nowcast struct {
+	Timezone int             // shift in seconds from UTC
+	Minutes  []Precipitation // minute by minute for the next hour, may be empty
+	Hours    []Precipitation // hourly, for the next two days
+}
+
+type Precipitation struct {
+	Time      time.Time
+	Intensity float64 // mm/h of rain and snow together
+	Pop       float64 // probability of precipitation, 0..1, hourly only
+}
+
+type AirPollution struct {
+	Current  AirQuality
+	Forecast []AirQuality // hourly
+}
+
+// AirQuality is the air quality index, from 1 (good) to 5 (very poor), and
+// the concentrations of pollutants in μg/m³.
+type AirQuality struct {
+	Time time.Time
+	AQI  int
+	CO   float64
+	NO   float64
+	NO2  float64
+	O3   float64
+	SO2  float64
+	PM25 float64
+	PM10 float64
+	NH3  float64
+}